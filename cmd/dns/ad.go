@@ -0,0 +1,320 @@
+package dns
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/cmd/ldap"
+)
+
+const DEFAULT_AD_SITE = "Default-First-Site-Name"
+
+// SRV owners a domain controller registers under the AD domain
+var adServices = []string{
+	"_ldap._tcp.dc._msdcs",
+	"_ldap._tcp.pdc._msdcs",
+	"_ldap._tcp.gc._msdcs",
+	"_kerberos._tcp.dc._msdcs",
+	"_ldap._tcp",
+	"_kerberos._tcp",
+	"_kerberos._udp",
+	"_kpasswd._tcp",
+	"_kpasswd._udp",
+	"_gc._tcp",
+}
+
+// Per site SRV owners, %s is the site name
+var adSiteServices = []string{
+	"_ldap._tcp.%s._sites.dc._msdcs",
+	"_kerberos._tcp.%s._sites.dc._msdcs",
+	"_ldap._tcp.%s._sites",
+	"_kerberos._tcp.%s._sites",
+	"_gc._tcp.%s._sites",
+}
+
+var dsaGUID = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// DomainController is a host named by the domain's SRV records. ADRecord is set
+// once an AD only record names it, plain _kerberos and _ldap records are also
+// used by MIT or Heimdal realms and only count when the NetLogon ping answers.
+type DomainController struct {
+	Name     string
+	Addrs    []string
+	Services []string
+	GUID     string
+	ADRecord bool
+	Netlogon *ldap.Netlogon
+	Err      error
+}
+
+// ADDiscovery finds the domain controllers of an Active Directory domain from
+// its SRV records and asks each one about itself with a CLDAP NetLogon ping.
+type ADDiscovery struct {
+	Domain     string
+	Nameserver string
+	Threads    int
+	Timeout    time.Duration
+	Services   map[string][]string
+	DCs        map[string]*DomainController
+	Sites      []string
+	mu         sync.Mutex
+}
+
+func NewADDiscovery(domain, nameserver string, threads int, timeout time.Duration) *ADDiscovery {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	if timeout <= 0 {
+		timeout = DEFAULT_CERT_TIMEOUT
+	}
+	return &ADDiscovery{
+		Domain:     dns.Fqdn(strings.ToLower(domain)),
+		Nameserver: nameserver,
+		Threads:    threads,
+		Timeout:    timeout,
+		Services:   make(map[string][]string),
+		DCs:        make(map[string]*DomainController),
+		Sites:      []string{DEFAULT_AD_SITE},
+	}
+}
+
+// AddRecords picks up DSA GUID CNAMEs and AD SRV records that were already collected.
+func (a *ADDiscovery) AddRecords(r *Records) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rr := range r.Data[dns.TypeCNAME] {
+		cname, ok := rr.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+		labels := dns.SplitDomainName(strings.ToLower(cname.Hdr.Name))
+		if len(labels) > 2 && labels[1] == "_msdcs" && dsaGUID.MatchString(labels[0]) {
+			dc := a.dc(cname.Target)
+			dc.GUID, dc.ADRecord = labels[0], true
+		}
+	}
+	for _, rr := range r.Data[dns.TypeSRV] {
+		if srv, ok := rr.(*dns.SRV); ok && isADService(srv.Hdr.Name) {
+			a.addSRV(srv)
+		}
+	}
+}
+
+// isADService matches SRV owners only a domain controller registers.
+func isADService(owner string) bool {
+	owner = strings.ToLower(owner)
+	return strings.Contains(owner, "._msdcs.") || strings.Contains(owner, "._sites.")
+}
+
+func (a *ADDiscovery) dc(name string) *DomainController {
+	name = dns.Fqdn(strings.ToLower(name))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if dc, ok := a.DCs[name]; ok {
+		return dc
+	}
+	dc := &DomainController{Name: name}
+	a.DCs[name] = dc
+	return dc
+}
+
+func (a *ADDiscovery) addSRV(srv *dns.SRV) {
+	if srv.Target == "." {
+		return
+	}
+	owner := strings.ToLower(srv.Hdr.Name)
+	entry := fmt.Sprintf("%s:%d", strings.ToLower(srv.Target), srv.Port)
+	dc := a.dc(srv.Target)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !slices.Contains(a.Services[owner], entry) {
+		a.Services[owner] = append(a.Services[owner], entry)
+	}
+	service := strings.TrimSuffix(owner, "."+a.Domain)
+	if !slices.Contains(dc.Services, service) {
+		dc.Services = append(dc.Services, service)
+	}
+	dc.ADRecord = dc.ADRecord || isADService(owner)
+}
+
+func (a *ADDiscovery) querySRV(owners []string) {
+	for _, owner := range owners {
+		rrs, err := lookup(owner+"."+a.Domain, dns.TypeSRV, a.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			if srv, ok := rr.(*dns.SRV); ok {
+				a.addSRV(srv)
+			}
+		}
+	}
+}
+
+func (a *ADDiscovery) Run() {
+	a.querySRV(adServices)
+	a.ping()
+
+	// Sites named by the DCs lead to site specific SRV records and possibly more DCs
+	queried := make([]string, 0)
+	for {
+		sites := slices.DeleteFunc(slices.Clone(a.Sites), func(s string) bool { return slices.Contains(queried, s) })
+		if len(sites) == 0 {
+			break
+		}
+		for _, site := range sites {
+			owners := make([]string, 0, len(adSiteServices))
+			for _, format := range adSiteServices {
+				owners = append(owners, fmt.Sprintf(format, site))
+			}
+			a.querySRV(owners)
+			queried = append(queried, site)
+		}
+		a.ping()
+	}
+}
+
+// ping resolves and pings every DC that has not been asked yet.
+func (a *ADDiscovery) ping() {
+	tasks := make(chan *DomainController, 100)
+	var wg sync.WaitGroup
+
+	go func() {
+		for _, dc := range a.candidates() {
+			if dc.Netlogon == nil && dc.Err == nil {
+				tasks <- dc
+			}
+		}
+		close(tasks)
+	}()
+	for i := 0; i < a.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dc := range tasks {
+				a.pingDC(dc)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (a *ADDiscovery) pingDC(dc *DomainController) {
+	addrs := resolveAddrs(dc.Name, a.Nameserver)
+	var response *ldap.Netlogon
+	err := fmt.Errorf("No Address")
+	for _, addr := range addrs {
+		if response, err = ldap.NetlogonPing(addr, ldap.CLDAP_PORT, a.Domain, "", a.Timeout); err == nil {
+			break
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dc.Addrs, dc.Netlogon, dc.Err = addrs, response, err
+	if err != nil {
+		dc.Netlogon = nil
+		return
+	}
+	for _, site := range []string{response.DCSite, response.ClientSite} {
+		if site != "" && !slices.Contains(a.Sites, site) {
+			a.Sites = append(a.Sites, site)
+		}
+	}
+}
+
+// candidates returns every SRV target, confirmed or not.
+func (a *ADDiscovery) candidates() []*DomainController {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dcs := make([]*DomainController, 0, len(a.DCs))
+	for _, dc := range a.DCs {
+		dcs = append(dcs, dc)
+	}
+	sort.Slice(dcs, func(i, j int) bool { return dcs[i].Name < dcs[j].Name })
+	return dcs
+}
+
+// controllers returns the targets named by an AD only record or answering the NetLogon ping.
+func (a *ADDiscovery) controllers() []*DomainController {
+	return slices.DeleteFunc(a.candidates(), func(dc *DomainController) bool {
+		return !dc.ADRecord && dc.Netlogon == nil
+	})
+}
+
+func (a *ADDiscovery) Names() []string {
+	names := make([]string, 0)
+	for _, dc := range a.controllers() {
+		names = append(names, dc.Name)
+	}
+	return names
+}
+
+func (a *ADDiscovery) Addresses() map[string][]string {
+	addresses := make(map[string][]string)
+	for _, dc := range a.controllers() {
+		for _, addr := range dc.Addrs {
+			addNamed(addresses, addr, dc.Name)
+		}
+	}
+	return addresses
+}
+
+func (a *ADDiscovery) Print() {
+	color.Blue("[ Active Directory Services ]")
+	owners := make([]string, 0, len(a.Services))
+	for owner := range a.Services {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	if len(owners) == 0 {
+		fmt.Printf("  |_____No AD SRV records\n\n")
+	}
+	for i, owner := range owners {
+		line := fmt.Sprintf("%s\t%s", owner, strings.Join(a.Services[owner], ", "))
+		if i == len(owners)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+
+	color.Blue("[ Domain Controllers ]")
+	for _, dc := range a.controllers() {
+		color.Yellow("  [ %s ] %s", dc.Name, orDash(strings.Join(dc.Addrs, ", ")))
+		lines := make([]string, 0)
+		if dc.GUID != "" {
+			lines = append(lines, "DSA GUID: "+dc.GUID)
+		}
+		if len(dc.Services) > 0 {
+			sort.Strings(dc.Services)
+			lines = append(lines, "Services: "+strings.Join(dc.Services, ", "))
+		}
+		if n := dc.Netlogon; n != nil {
+			lines = append(lines,
+				"Forest: "+n.Forest,
+				"Domain: "+n.Domain+" ("+n.NetbiosDomain+")",
+				"Host: "+n.HostName+" ("+n.NetbiosName+")",
+				"Site: "+orDash(n.DCSite)+" | Client Site: "+orDash(n.ClientSite),
+				"Domain GUID: "+n.DomainGUID,
+				"Flags: 0x"+strconv.FormatUint(uint64(n.Flags), 16)+" "+strings.Join(n.FlagNames(), ","),
+			)
+		} else if dc.Err != nil {
+			lines = append(lines, color.RedString("NetLogon: %v", dc.Err))
+		}
+		for i, line := range lines {
+			if i == len(lines)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+}
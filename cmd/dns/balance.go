@@ -0,0 +1,396 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+const (
+	DEFAULT_LB_ROUNDS   = 5
+	DEFAULT_LB_INTERVAL = 2 * time.Second
+	// Records published with a TTL this short are meant to be steered
+	LB_LOW_TTL = 60
+
+	LB_STATIC      = "static"
+	LB_ROUND_ROBIN = "round-robin"
+	LB_GSLB        = "GSLB/CDN"
+)
+
+// Public resolvers queried alongside the nameserver unless --resolvers is given
+var defaultLBResolvers = []string{
+	"8.8.8.8",        // Google
+	"1.1.1.1",        // Cloudflare
+	"9.9.9.9",        // Quad9
+	"208.67.222.222", // OpenDNS
+}
+
+var lbTypes = [...]uint16{
+	dns.TypeA,
+	dns.TypeAAAA,
+}
+
+type lbTask struct {
+	Name     string
+	Resolver string
+	Round    int
+}
+
+// LBAnswer is one A/AAAA answer for a name, addresses kept in the order served.
+type LBAnswer struct {
+	Resolver string
+	Round    int
+	CNAME    []string
+	A        []string
+	AAAA     []string
+	TTL      uint32
+	Err      error
+}
+
+// Answers returns the A and AAAA addresses in the order served.
+func (a LBAnswer) Answers() []string {
+	return append(slices.Clone(a.A), a.AAAA...)
+}
+
+// key returns the A and AAAA sets without their order, compared separately.
+func (a LBAnswer) key() string {
+	return lbSet(a.A) + "|" + lbSet(a.AAAA)
+}
+
+// complete reports whether both queries were answered, a failed one would look like a changed set.
+func (a LBAnswer) complete() bool {
+	return a.Err == nil && len(a.A)+len(a.AAAA) > 0
+}
+
+func lbSet(addrs []string) string {
+	addrs = slices.Clone(addrs)
+	sort.Strings(addrs)
+	return strings.Join(addrs, ",")
+}
+
+type LBHost struct {
+	Name     string
+	Class    string
+	CDN      string
+	Backends []string
+	CNAME    []string
+	Sets     int
+	TTL      uint32
+	Signals  []string
+	Answers  []LBAnswer
+}
+
+// LoadBalanceProbe repeats A/AAAA queries over several rounds and resolvers
+// and tells static hosts from DNS round-robin and GSLB or CDN steering.
+type LoadBalanceProbe struct {
+	Resolvers []string
+	Threads   int
+	Rounds    int
+	Interval  time.Duration
+	Verbose   bool
+	Hosts     map[string]*LBHost
+	mu        sync.Mutex
+}
+
+func NewLoadBalanceProbe(nameserver string, threads, rounds int, interval time.Duration, resolvers []string) *LoadBalanceProbe {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	if rounds <= 0 {
+		rounds = DEFAULT_LB_ROUNDS
+	}
+	if len(resolvers) == 0 {
+		resolvers = defaultLBResolvers
+	}
+	probe := &LoadBalanceProbe{
+		Resolvers: []string{nameserver},
+		Threads:   threads,
+		Rounds:    rounds,
+		Interval:  interval,
+		Hosts:     make(map[string]*LBHost),
+	}
+	for _, resolver := range resolvers {
+		if resolver = strings.TrimSpace(resolver); resolver != "" && !slices.Contains(probe.Resolvers, resolver) {
+			probe.Resolvers = append(probe.Resolvers, resolver)
+		}
+	}
+	return probe
+}
+
+func (l *LoadBalanceProbe) Probe(names []string) {
+	for round := 0; round < l.Rounds; round++ {
+		if round > 0 {
+			time.Sleep(l.Interval)
+		}
+		tasks := make(chan lbTask, 100)
+		var wg sync.WaitGroup
+
+		go func() {
+			for _, name := range names {
+				for _, resolver := range l.Resolvers {
+					tasks <- lbTask{dns.Fqdn(strings.ToLower(name)), resolver, round}
+				}
+			}
+			close(tasks)
+		}()
+		for i := 0; i < l.Threads; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for task := range tasks {
+					answer := l.query(task)
+					l.mu.Lock()
+					host, ok := l.Hosts[task.Name]
+					if !ok {
+						host = &LBHost{Name: task.Name}
+						l.Hosts[task.Name] = host
+					}
+					host.Answers = append(host.Answers, answer)
+					l.mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, host := range l.Hosts {
+		l.classify(host)
+		if len(host.Backends) == 0 {
+			delete(l.Hosts, name)
+		}
+	}
+}
+
+func (l *LoadBalanceProbe) query(task lbTask) LBAnswer {
+	answer := LBAnswer{Resolver: task.Resolver, Round: task.Round, CNAME: make([]string, 0), A: make([]string, 0), AAAA: make([]string, 0)}
+	for _, qtype := range lbTypes {
+		in, err := exchange(task.Name, qtype, task.Resolver)
+		if err == nil && in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+			err = fmt.Errorf("%s", dns.RcodeToString[in.Rcode])
+		}
+		if err != nil {
+			answer.Err = err
+			continue
+		}
+		for _, rr := range in.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				answer.A = append(answer.A, v.A.String())
+			case *dns.AAAA:
+				answer.AAAA = append(answer.AAAA, v.AAAA.String())
+			case *dns.CNAME:
+				answer.CNAME = append(answer.CNAME, strings.ToLower(v.Target))
+				continue
+			default:
+				continue
+			}
+			answer.TTL = max(answer.TTL, rr.Header().Ttl)
+		}
+	}
+	answer.CNAME = slices.Compact(answer.CNAME)
+	return answer
+}
+
+// classify looks at how the answers moved between rounds and resolvers.
+// Recursive resolvers hand out the remaining cache lifetime, so the largest
+// TTL seen is the one closest to what the zone publishes.
+func (l *LoadBalanceProbe) classify(host *LBHost) {
+	sort.Slice(host.Answers, func(i, j int) bool {
+		if host.Answers[i].Resolver != host.Answers[j].Resolver {
+			return slices.Index(l.Resolvers, host.Answers[i].Resolver) < slices.Index(l.Resolvers, host.Answers[j].Resolver)
+		}
+		return host.Answers[i].Round < host.Answers[j].Round
+	})
+	host.Backends, host.CNAME, host.Signals = make([]string, 0), make([]string, 0), make([]string, 0)
+	setsA, setsAAAA := make(map[string]bool), make(map[string]bool)
+	orders := make(map[string][]string)
+	// A dual stack host has one address per family and is still a single backend
+	backendsA, backendsAAAA := make(map[string]bool), make(map[string]bool)
+	for _, answer := range host.Answers {
+		host.Backends = append(host.Backends, answer.Answers()...)
+		for _, addr := range answer.A {
+			backendsA[addr] = true
+		}
+		for _, addr := range answer.AAAA {
+			backendsAAAA[addr] = true
+		}
+		host.CNAME = append(host.CNAME, answer.CNAME...)
+		if !answer.complete() {
+			continue
+		}
+		setsA[lbSet(answer.A)] = true
+		setsAAAA[lbSet(answer.AAAA)] = true
+		for _, family := range [][]string{answer.A, answer.AAAA} {
+			set, order := lbSet(family), strings.Join(family, ",")
+			if !slices.Contains(orders[set], order) {
+				orders[set] = append(orders[set], order)
+			}
+		}
+		host.TTL = max(host.TTL, answer.TTL)
+	}
+	sort.Strings(host.Backends)
+	host.Backends = slices.Compact(host.Backends)
+	sort.Strings(host.CNAME)
+	host.CNAME = slices.Compact(host.CNAME)
+	host.Sets = max(len(setsA), len(setsAAAA))
+	for _, target := range host.CNAME {
+		if provider := cdnProvider(target); provider != "" {
+			host.CDN = provider
+			host.Signals = append(host.Signals, "CNAME into "+provider)
+			break
+		}
+	}
+
+	rotates := false
+	for _, list := range orders {
+		rotates = rotates || len(list) > 1
+	}
+	if len(setsA) > 1 {
+		host.Signals = append(host.Signals, fmt.Sprintf("%d different A answer sets", len(setsA)))
+	}
+	if len(setsAAAA) > 1 {
+		host.Signals = append(host.Signals, fmt.Sprintf("%d different AAAA answer sets", len(setsAAAA)))
+	}
+	if host.Sets > 1 {
+		if l.perResolver(host) {
+			host.Signals = append(host.Signals, "answers differ between resolvers")
+		}
+		if l.perRound(host) {
+			host.Signals = append(host.Signals, "answers change between rounds")
+		}
+	}
+	if rotates {
+		host.Signals = append(host.Signals, "answer order rotates")
+	}
+	lowTTL := host.TTL > 0 && host.TTL <= LB_LOW_TTL
+	if lowTTL {
+		host.Signals = append(host.Signals, fmt.Sprintf("low TTL %ds", host.TTL))
+	}
+
+	switch {
+	case host.CDN != "" || host.Sets > 1 || lowTTL:
+		host.Class = LB_GSLB
+	case len(backendsA) > 1 || len(backendsAAAA) > 1 || rotates:
+		host.Class = LB_ROUND_ROBIN
+	default:
+		host.Class = LB_STATIC
+	}
+}
+
+// perResolver reports whether resolvers disagree within the same round.
+func (l *LoadBalanceProbe) perResolver(host *LBHost) bool {
+	rounds := make(map[int]string)
+	for _, answer := range host.Answers {
+		if !answer.complete() {
+			continue
+		}
+		if key, ok := rounds[answer.Round]; ok && key != answer.key() {
+			return true
+		}
+		rounds[answer.Round] = answer.key()
+	}
+	return false
+}
+
+// perRound reports whether a single resolver handed out different sets over time.
+func (l *LoadBalanceProbe) perRound(host *LBHost) bool {
+	resolvers := make(map[string]string)
+	for _, answer := range host.Answers {
+		if !answer.complete() {
+			continue
+		}
+		if key, ok := resolvers[answer.Resolver]; ok && key != answer.key() {
+			return true
+		}
+		resolvers[answer.Resolver] = answer.key()
+	}
+	return false
+}
+
+// cdnProvider matches a CNAME target against the CDN signatures of the provider database.
+func cdnProvider(target string) string {
+	target = "." + dns.Fqdn(strings.ToLower(target))
+	for _, sig := range providerSignatures {
+		if sig.Kind == SIG_CNAME && sig.Category == "CDN" && strings.HasSuffix(target, sig.Pattern) {
+			return sig.Provider
+		}
+	}
+	return ""
+}
+
+func (l *LoadBalanceProbe) sorted() []*LBHost {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hosts := make([]*LBHost, 0, len(l.Hosts))
+	for _, host := range l.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts
+}
+
+// Count returns how many hosts fall into a class.
+func (l *LoadBalanceProbe) Count(class string) int {
+	count := 0
+	for _, host := range l.sorted() {
+		if host.Class == class {
+			count++
+		}
+	}
+	return count
+}
+
+// Addresses returns every backend seen, mapped to the names serving it.
+func (l *LoadBalanceProbe) Addresses() map[string][]string {
+	addresses := make(map[string][]string)
+	for _, host := range l.sorted() {
+		for _, backend := range host.Backends {
+			addNamed(addresses, backend, host.Name)
+		}
+	}
+	return addresses
+}
+
+func (l *LoadBalanceProbe) Print() {
+	color.Blue("[ Load Balancing ]")
+	fmt.Printf("  |_____Resolvers: %s | Rounds: %d every %s\n\n", strings.Join(l.Resolvers, ", "), l.Rounds, l.Interval)
+	for _, host := range l.sorted() {
+		header := fmt.Sprintf("  [ %s ] %s", host.Name, host.Class)
+		if host.Class != LB_STATIC {
+			color.Yellow(header)
+		} else {
+			fmt.Println(header)
+		}
+		lines := []string{
+			fmt.Sprintf("Backends (%d): %s", len(host.Backends), strings.Join(host.Backends, ", ")),
+			fmt.Sprintf("Answer sets: %d | TTL: %ds", host.Sets, host.TTL),
+		}
+		if len(host.CNAME) > 0 {
+			lines = append(lines, "CNAME: "+strings.Join(host.CNAME, ", "))
+		}
+		if l.Verbose {
+			for _, answer := range host.Answers {
+				line := fmt.Sprintf("%s\tround %d\tTTL %d\t%s", answer.Resolver, answer.Round+1, answer.TTL, orDash(strings.Join(answer.Answers(), ", ")))
+				if answer.Err != nil {
+					line += fmt.Sprintf("\t(%v)", answer.Err)
+				}
+				lines = append(lines, line)
+			}
+		}
+		lines = append(lines, "Signals: "+orDash(strings.Join(host.Signals, " | ")))
+		for i, line := range lines {
+			if i == len(lines)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+}
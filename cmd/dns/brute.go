@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Every word is tried for both address families so AAAA only names are found too
+var bruteTypes = [...]uint16{
+	dns.TypeA,
+	dns.TypeAAAA,
+}
+
+// Brute resolves word.domain for every word through the mass resolution
+// engine and drops any answer that only matches the domain's wildcard.
+func Brute(domain string, words []string, engine *Engine) (*Inventory, error) {
+	domain = dns.Fqdn(strings.ToLower(domain))
+	wildcard := probeWildcard(domain, engine.Nameserver)
+	queries := make(chan Query, 1000)
+	results, err := engine.Run(queries)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for _, word := range words {
+			word = strings.Trim(strings.ToLower(word), ".")
+			if word == "" {
+				continue
+			}
+			for _, qtype := range bruteTypes {
+				queries <- Query{word + "." + domain, qtype}
+			}
+		}
+		close(queries)
+	}()
+
+	hits := NewInventory()
+	for res := range results {
+		if res.Err != nil || res.Msg == nil || res.Msg.Rcode != dns.RcodeSuccess {
+			continue
+		}
+		name := strings.ToLower(res.Name)
+		host, ok := hits.Hosts[name]
+		if !ok {
+			host = &Host{Name: name, Source: "brute"}
+		}
+		for _, rr := range res.Msg.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				host.Addrs = append(host.Addrs, v.A.String())
+			case *dns.AAAA:
+				host.Addrs = append(host.Addrs, v.AAAA.String())
+			case *dns.CNAME:
+				if target := strings.ToLower(v.Target); !slices.Contains(host.Chain, target) {
+					host.Chain = append(host.Chain, target)
+				}
+			}
+		}
+		if len(host.Addrs) == 0 && len(host.Chain) == 0 {
+			continue
+		}
+		sort.Strings(host.Addrs)
+		host.Addrs = slices.Compact(host.Addrs)
+		hits.Hosts[name] = host
+	}
+	// Filter once both families are merged, a wildcard may answer only one of them
+	for name, host := range hits.Hosts {
+		if len(wildcard) > 0 && len(host.Addrs) > 0 && !slices.ContainsFunc(host.Addrs, func(a string) bool {
+			return !slices.Contains(wildcard, a)
+		}) {
+			delete(hits.Hosts, name)
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,236 @@
+package dns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/cmd/mail"
+)
+
+const DEFAULT_CERT_TIMEOUT = 3 * time.Second
+
+// Implicit TLS services: HTTPS, SMTPS, IMAPS, POP3S, LDAPS and alternate HTTPS
+var TLSPorts = []int{443, 465, 993, 995, 636, 8443}
+
+// SMTP submission ports upgraded with STARTTLS
+var STARTTLSPorts = []int{25, 587}
+
+type certTask struct {
+	Address string
+	Port    int
+	SNI     string
+}
+
+type CertResult struct {
+	Address  string
+	Port     int
+	SNI      string
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	Names    []string
+}
+
+// CertHarvester pulls certificate chains from every resolved address and
+// collects the SAN and CN hostnames they carry.
+type CertHarvester struct {
+	Domain  string
+	Threads int
+	Timeout time.Duration
+	Ports   []int
+	Results []*CertResult
+	mu      sync.Mutex
+}
+
+func NewCertHarvester(domain string, threads int, timeout time.Duration, ports []int) *CertHarvester {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	if timeout <= 0 {
+		timeout = DEFAULT_CERT_TIMEOUT
+	}
+	if len(ports) == 0 {
+		ports = append(slices.Clone(TLSPorts), STARTTLSPorts...)
+	}
+	return &CertHarvester{
+		Domain:  dns.Fqdn(strings.ToLower(domain)),
+		Threads: threads,
+		Timeout: timeout,
+		Ports:   ports,
+		Results: make([]*CertResult, 0),
+	}
+}
+
+// Harvest connects to every address and port, using the first name pointing
+// at an address for SNI.
+func (c *CertHarvester) Harvest(addresses map[string][]string) {
+	tasks := make(chan certTask, 100)
+	var wg sync.WaitGroup
+
+	go func() {
+		for addr, names := range addresses {
+			sni := ""
+			if len(names) > 0 {
+				sni = strings.TrimSuffix(names[0], ".")
+			}
+			for _, port := range c.Ports {
+				tasks <- certTask{addr, port, sni}
+			}
+		}
+		close(tasks)
+	}()
+	for i := 0; i < c.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				result, err := c.grab(task)
+				if err != nil {
+					continue
+				}
+				c.mu.Lock()
+				c.Results = append(c.Results, result)
+				c.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *CertHarvester) grab(task certTask) (*CertResult, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(task.Address, strconv.Itoa(task.Port)), c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	// Only the names are wanted, the chain does not have to validate
+	config := &tls.Config{ServerName: task.SNI, InsecureSkipVerify: true}
+	var tlsConn *tls.Conn
+	if slices.Contains(STARTTLSPorts, task.Port) {
+		if tlsConn, err = mail.StartTLS(conn, config); err != nil {
+			return nil, err
+		}
+	} else {
+		tlsConn = tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, fmt.Errorf("TLS Handshake Error: %v", err)
+		}
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No Certificates: %s:%d", task.Address, task.Port)
+	}
+	leaf := certs[0]
+	result := &CertResult{
+		Address:  task.Address,
+		Port:     task.Port,
+		SNI:      task.SNI,
+		Subject:  leaf.Subject.CommonName,
+		Issuer:   leaf.Issuer.CommonName,
+		NotAfter: leaf.NotAfter,
+		Names:    make([]string, 0),
+	}
+	// Intermediates and roots name the CA, not the host
+	for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !strings.Contains(name, ".") {
+			continue
+		}
+		if _, ok := dns.IsDomainName(name); !ok || net.ParseIP(name) != nil {
+			continue
+		}
+		result.Names = append(result.Names, dns.Fqdn(name))
+	}
+	sort.Strings(result.Names)
+	result.Names = slices.Compact(result.Names)
+	return result, nil
+}
+
+// Names returns every hostname seen in any certificate, wildcards included.
+func (c *CertHarvester) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0)
+	for _, result := range c.Results {
+		names = append(names, result.Names...)
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// InScope returns the resolvable names under the target domain, wildcards reduced to their base.
+func (c *CertHarvester) InScope() []string {
+	names := make([]string, 0)
+	for _, name := range c.Names() {
+		name = strings.TrimPrefix(name, "*.")
+		if dns.IsSubDomain(c.Domain, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+func (c *CertHarvester) Print() {
+	c.mu.Lock()
+	results := slices.Clone(c.Results)
+	c.mu.Unlock()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Address != results[j].Address {
+			return results[i].Address < results[j].Address
+		}
+		return results[i].Port < results[j].Port
+	})
+	if len(results) == 0 {
+		fmt.Printf("  |_____No certificates\n\n")
+		return
+	}
+	for _, result := range results {
+		header := fmt.Sprintf("  [ %s ] CN: %s | Issuer: %s | Expires: %s", net.JoinHostPort(result.Address, strconv.Itoa(result.Port)),
+			orDash(result.Subject), orDash(result.Issuer), result.NotAfter.Format(TIME_FORMAT))
+		if result.NotAfter.Before(time.Now()) {
+			color.Yellow(header + " (expired)")
+		} else {
+			fmt.Println(header)
+		}
+		if len(result.Names) == 0 {
+			fmt.Printf("  |_____-\n\n")
+			continue
+		}
+		for i, name := range result.Names {
+			if !dns.IsSubDomain(c.Domain, strings.TrimPrefix(name, "*.")) {
+				name += " (out of scope)"
+			}
+			if i == len(result.Names)-1 {
+				fmt.Printf("  |_____%s\n\n", name)
+				break
+			}
+			fmt.Printf("  | \t%s\n", name)
+		}
+	}
+}
+
+// parsePorts reads a comma separated list of ports.
+func parsePorts(value string) ([]int, error) {
+	ports := make([]int, 0)
+	for _, p := range splitList(value) {
+		port, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("Invalid Port: %s", p)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
@@ -0,0 +1,191 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+const CATEGORY_TAKEOVER = "Takeover"
+
+type dnsProvider struct {
+	Name    string
+	Pattern string
+}
+
+// Managed DNS providers where a zone removed from one account can be created
+// again by anyone, matched against the NS host name
+var dnsProviders = []dnsProvider{
+	{"AWS Route 53", ".awsdns-"},
+	{"Azure DNS", ".azure-dns."},
+	{"Google Cloud DNS", ".googledomains.com."},
+	{"DigitalOcean", ".digitalocean.com."},
+	{"Linode", ".linode.com."},
+	{"NS1", ".nsone.net."},
+	{"DNSimple", ".dnsimple.com."},
+	{"DNS Made Easy", ".dnsmadeeasy.com."},
+	{"Hurricane Electric", ".he.net."},
+	{"GoDaddy", ".domaincontrol.com."},
+	{"Cloudflare", ".cloudflare.com."},
+	{"Hetzner", ".hetzner.com."},
+	{"Vultr", ".vultr.com."},
+	{"UltraDNS", ".ultradns."},
+	{"Gandi", ".gandi.net."},
+	{"Namecheap", ".registrar-servers.com."},
+	{"Rackspace", ".stabletransit.com."},
+	{"Yandex", ".yandex.net."},
+}
+
+func matchProvider(host string) string {
+	host = "." + dns.Fqdn(strings.ToLower(host))
+	for _, provider := range dnsProviders {
+		if strings.Contains(host, provider.Pattern) {
+			return provider.Name
+		}
+	}
+	return ""
+}
+
+type NSCheck struct {
+	Zone   string
+	Host   string
+	Status string
+}
+
+// DanglingAudit looks for delegations a third party could claim: NS hosts
+// under unregistered domains, and providers that no longer host the zone.
+type DanglingAudit struct {
+	Domain      string
+	Nameserver  string
+	Zones       map[string][]string
+	Checks      []NSCheck
+	Findings    *Findings
+	registrable map[string]int
+}
+
+func NewDanglingAudit(domain, nameserver string) *DanglingAudit {
+	return &DanglingAudit{
+		Domain:      dns.Fqdn(strings.ToLower(domain)),
+		Nameserver:  nameserver,
+		Zones:       make(map[string][]string),
+		Checks:      make([]NSCheck, 0),
+		Findings:    NewFindings(),
+		registrable: make(map[string]int),
+	}
+}
+
+// AddRecords collects the NS records of the domain and every subzone.
+func (d *DanglingAudit) AddRecords(r *Records) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rr := range r.Data[dns.TypeNS] {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		zone := strings.ToLower(ns.Hdr.Name)
+		host := strings.ToLower(ns.Ns)
+		if dns.IsSubDomain(d.Domain, zone) && !slices.Contains(d.Zones[zone], host) {
+			d.Zones[zone] = append(d.Zones[zone], host)
+		}
+	}
+}
+
+func (d *DanglingAudit) Run() {
+	if len(d.Zones[d.Domain]) == 0 {
+		rrs, _ := lookup(d.Domain, dns.TypeNS, d.Nameserver)
+		d.AddRecords(&Records{Data: map[uint16][]dns.RR{dns.TypeNS: rrs}})
+	}
+	zones := make([]string, 0, len(d.Zones))
+	for zone := range d.Zones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		hosts := d.Zones[zone]
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			d.Checks = append(d.Checks, NSCheck{zone, host, d.check(zone, host)})
+		}
+	}
+}
+
+func (d *DanglingAudit) check(zone, host string) string {
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(host, "."))
+	if err == nil {
+		registrable = dns.Fqdn(registrable)
+		if rcode := d.rcode(registrable); rcode == dns.RcodeNameError {
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_TAKEOVER, zone, fmt.Sprintf(
+				"NS %s lives under %s which returns NXDOMAIN, registering it hands over the whole zone (evidence: %s SOA -> NXDOMAIN)",
+				host, registrable, registrable))
+			return fmt.Sprintf("unregistered domain %s", registrable)
+		}
+	}
+
+	addrs := resolveAddrs(host, d.Nameserver)
+	if len(addrs) == 0 {
+		return "no address"
+	}
+	provider := matchProvider(host)
+	for _, addr := range addrs {
+		msg := new(dns.Msg)
+		msg.SetQuestion(zone, dns.TypeSOA)
+		msg.RecursionDesired = false
+		in, err := exchangeMsg(msg, addr)
+		if err != nil || (in.Rcode != dns.RcodeRefused && in.Rcode != dns.RcodeServerFailure) {
+			continue
+		}
+		rcode := dns.RcodeToString[in.Rcode]
+		evidence := fmt.Sprintf("evidence: %s SOA @%s -> %s", zone, addr, rcode)
+		if provider != "" {
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_TAKEOVER, zone, fmt.Sprintf(
+				"delegated to %s (%s) which no longer hosts the zone, it may be claimable from a new account (%s)", host, provider, evidence))
+			return fmt.Sprintf("%s at %s (%s)", rcode, addr, provider)
+		}
+		d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_TAKEOVER, zone, fmt.Sprintf(
+			"NS %s does not serve the zone, stale delegation (%s)", host, evidence))
+		return fmt.Sprintf("%s at %s", rcode, addr)
+	}
+	if provider != "" {
+		return "ok (" + provider + ")"
+	}
+	return "ok"
+}
+
+// rcode resolves the SOA of a registrable domain once and remembers the answer code.
+func (d *DanglingAudit) rcode(registrable string) int {
+	if rcode, ok := d.registrable[registrable]; ok {
+		return rcode
+	}
+	rcode := -1
+	if in, err := exchange(registrable, dns.TypeSOA, d.Nameserver); err == nil {
+		rcode = in.Rcode
+	}
+	d.registrable[registrable] = rcode
+	return rcode
+}
+
+func (d *DanglingAudit) Print() {
+	color.Blue("[ Nameserver Takeover ]")
+	if len(d.Checks) == 0 {
+		fmt.Printf("  |_____No NS records\n\n")
+	}
+	for i, check := range d.Checks {
+		line := fmt.Sprintf("%s\t%s\t%s", check.Zone, check.Host, check.Status)
+		if !strings.HasPrefix(check.Status, "ok") {
+			line = color.YellowString(line)
+		}
+		if i == len(d.Checks)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+	color.Blue("[ Nameserver Takeover Findings ]")
+	d.Findings.Print()
+}
@@ -0,0 +1,342 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+const CATEGORY_DELEGATION = "Delegation"
+
+const (
+	SERVER_AUTHORITATIVE = "authoritative"
+	SERVER_LAME          = "lame (no AA bit)"
+	SERVER_UNREACHABLE   = "unreachable"
+	SERVER_UNRESOLVED    = "unresolved"
+)
+
+type ServerCheck struct {
+	Name    string
+	Address string
+	Status  string
+}
+
+func (s ServerCheck) Authoritative() bool {
+	return s.Status == SERVER_AUTHORITATIVE
+}
+
+// DelegationAudit compares what the parent delegates with what the zone's own
+// servers publish, and checks every one of them actually serves the zone.
+type DelegationAudit struct {
+	Domain     string
+	Nameserver string
+	Parent     string
+	ParentNS   []string
+	ChildNS    []string
+	Primary    string
+	Servers    []ServerCheck
+	Findings   *Findings
+}
+
+func NewDelegationAudit(domain, nameserver string) *DelegationAudit {
+	return &DelegationAudit{
+		Domain:     dns.Fqdn(strings.ToLower(domain)),
+		Nameserver: nameserver,
+		ParentNS:   make([]string, 0),
+		ChildNS:    make([]string, 0),
+		Servers:    make([]ServerCheck, 0),
+		Findings:   NewFindings(),
+	}
+}
+
+func (d *DelegationAudit) Run() {
+	d.ChildNS = d.nsNames(d.Domain)
+	d.Parent = d.parentZone()
+	d.ParentNS = d.delegation()
+
+	listed := append(slices.Clone(d.ParentNS), d.ChildNS...)
+	sort.Strings(listed)
+	listed = slices.Compact(listed)
+	for _, ns := range listed {
+		d.checkServer(ns)
+	}
+	d.checkReachability()
+	d.compareSets()
+	d.checkPrimary(listed)
+}
+
+// nsNames returns the NS targets of a zone as seen through the resolver.
+func (d *DelegationAudit) nsNames(zone string) []string {
+	names := make([]string, 0)
+	rrs, err := lookup(zone, dns.TypeNS, d.Nameserver)
+	if err != nil {
+		return names
+	}
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, zone) {
+			names = append(names, strings.ToLower(ns.Ns))
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// parentZone walks up from the domain to the closest enclosing zone apex.
+func (d *DelegationAudit) parentZone() string {
+	for parent := parentDomain(d.Domain); parent != "."; parent = parentDomain(parent) {
+		rrs, err := lookup(parent, dns.TypeSOA, d.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			if _, ok := rr.(*dns.SOA); ok && strings.EqualFold(rr.Header().Name, parent) {
+				return parent
+			}
+		}
+	}
+	return "."
+}
+
+// delegation asks the parent's servers directly for the referral and returns the NS set it carries.
+func (d *DelegationAudit) delegation() []string {
+	for _, server := range d.nsNames(d.Parent) {
+		for _, addr := range resolveAddrs(server, d.Nameserver) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(d.Domain, dns.TypeNS)
+			msg.RecursionDesired = false
+			in, err := exchangeMsg(msg, addr)
+			if err != nil || in.Rcode != dns.RcodeSuccess {
+				continue
+			}
+			names := make([]string, 0)
+			for _, rr := range append(in.Answer, in.Ns...) {
+				if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, d.Domain) {
+					names = append(names, strings.ToLower(ns.Ns))
+				}
+			}
+			if len(names) > 0 {
+				sort.Strings(names)
+				return slices.Compact(names)
+			}
+		}
+	}
+	d.Findings.Add(SEVERITY_INFO, CATEGORY_DELEGATION, d.Parent, "no parent server returned a referral, parent NS set unknown")
+	return make([]string, 0)
+}
+
+// checkServer queries every address of a nameserver for the zone SOA without recursion.
+func (d *DelegationAudit) checkServer(ns string) {
+	checks := make([]ServerCheck, 0)
+	addrs, err := resolveHost(ns, d.Nameserver)
+	switch {
+	case len(addrs) > 0:
+	case err != nil:
+		// The resolver failed, that says nothing about the NS record itself
+		d.Findings.Add(SEVERITY_LOW, CATEGORY_DELEGATION, ns, fmt.Sprintf("nameserver could not be resolved: %v", err))
+		checks = append(checks, ServerCheck{ns, "-", SERVER_UNRESOLVED})
+	default:
+		d.Findings.Add(SEVERITY_HIGH, CATEGORY_DELEGATION, ns, "nameserver has no A or AAAA record, stale NS record")
+		checks = append(checks, ServerCheck{ns, "-", SERVER_UNREACHABLE})
+	}
+	for _, addr := range addrs {
+		checks = append(checks, ServerCheck{ns, addr, d.probe(addr)})
+	}
+	d.Servers = append(d.Servers, checks...)
+
+	// Only addresses that answered say anything about lameness, a timeout may be our own network
+	lame := make([]string, 0)
+	answered := 0
+	for _, check := range checks {
+		if check.Address == "-" || check.Status == SERVER_UNREACHABLE {
+			continue
+		}
+		answered++
+		if !check.Authoritative() {
+			lame = append(lame, fmt.Sprintf("%s: %s", check.Address, check.Status))
+		}
+	}
+	switch {
+	case len(lame) == 0:
+	case len(lame) == answered:
+		d.Findings.Add(SEVERITY_HIGH, CATEGORY_DELEGATION, ns, "lame delegation, no address answers authoritatively: "+strings.Join(lame, ", "))
+	default:
+		d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DELEGATION, ns, "partially lame: "+strings.Join(lame, ", "))
+	}
+}
+
+// checkReachability reports addresses that never answered. A family where no
+// address answered at all is reported once, it usually means the scanner has
+// no route for it, e.g. IPv6 from an IPv4 only host.
+func (d *DelegationAudit) checkReachability() {
+	total, unreachable := make(map[string]int), make(map[string][]string)
+	for _, check := range d.Servers {
+		if check.Address == "-" {
+			continue
+		}
+		family := addrFamily(check.Address)
+		total[family]++
+		if check.Status == SERVER_UNREACHABLE {
+			unreachable[family] = append(unreachable[family], check.Address)
+		}
+	}
+	down := make(map[string]bool)
+	for _, family := range []string{"IPv4", "IPv6"} {
+		if total[family] > 0 && len(unreachable[family]) == total[family] {
+			down[family] = true
+			d.Findings.Add(SEVERITY_INFO, CATEGORY_DELEGATION, d.Domain,
+				fmt.Sprintf("none of the %d %s nameserver addresses answered, %s may be unreachable from here", total[family], family, family))
+		}
+	}
+	missed := make(map[string][]string)
+	names := make([]string, 0)
+	for _, check := range d.Servers {
+		if check.Status != SERVER_UNREACHABLE || check.Address == "-" || down[addrFamily(check.Address)] {
+			continue
+		}
+		if _, ok := missed[check.Name]; !ok {
+			names = append(names, check.Name)
+		}
+		missed[check.Name] = append(missed[check.Name], check.Address)
+	}
+	for _, ns := range names {
+		d.Findings.Add(SEVERITY_LOW, CATEGORY_DELEGATION, ns, "no answer from "+strings.Join(missed[ns], ", "))
+	}
+}
+
+func addrFamily(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func (d *DelegationAudit) probe(addr string) string {
+	msg := new(dns.Msg)
+	msg.SetQuestion(d.Domain, dns.TypeSOA)
+	msg.RecursionDesired = false
+	in, err := exchangeMsg(msg, addr)
+	switch {
+	case err != nil:
+		return SERVER_UNREACHABLE
+	case in.Rcode != dns.RcodeSuccess:
+		return dns.RcodeToString[in.Rcode]
+	case !in.Authoritative:
+		return SERVER_LAME
+	}
+	return SERVER_AUTHORITATIVE
+}
+
+func (d *DelegationAudit) compareSets() {
+	if len(d.ParentNS) == 0 {
+		return
+	}
+	for _, ns := range d.ParentNS {
+		if !slices.Contains(d.ChildNS, ns) {
+			d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DELEGATION, ns, fmt.Sprintf("delegated by %s but missing from the zone NS set", d.Parent))
+		}
+	}
+	for _, ns := range d.ChildNS {
+		if !slices.Contains(d.ParentNS, ns) {
+			d.Findings.Add(SEVERITY_LOW, CATEGORY_DELEGATION, ns, fmt.Sprintf("listed in the zone but not delegated by %s", d.Parent))
+		}
+	}
+}
+
+// checkPrimary looks for a SOA MNAME that is left out of the NS sets but still answers.
+func (d *DelegationAudit) checkPrimary(listed []string) {
+	rrs, err := lookup(d.Domain, dns.TypeSOA, d.Nameserver)
+	if err != nil {
+		return
+	}
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			d.Primary = strings.ToLower(soa.Ns)
+		}
+	}
+	if d.Primary == "" || slices.Contains(listed, d.Primary) {
+		return
+	}
+	addrs, err := resolveHost(d.Primary, d.Nameserver)
+	answered := make([]string, 0)
+	for _, addr := range addrs {
+		status := d.probe(addr)
+		d.Servers = append(d.Servers, ServerCheck{d.Primary, addr, status + " (hidden primary)"})
+		switch status {
+		case SERVER_AUTHORITATIVE:
+			d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DELEGATION, d.Primary,
+				fmt.Sprintf("SOA MNAME is not in the NS set but answers authoritatively at %s, hidden primary", addr))
+			return
+		case SERVER_UNREACHABLE:
+		default:
+			answered = append(answered, fmt.Sprintf("%s: %s", addr, status))
+		}
+	}
+	detail := "SOA MNAME is not in the NS set and not reachable"
+	switch {
+	case len(answered) > 0:
+		detail = "SOA MNAME is not in the NS set and answers without authority: " + strings.Join(answered, ", ")
+	case len(addrs) == 0 && err != nil:
+		detail = fmt.Sprintf("SOA MNAME is not in the NS set and could not be resolved: %v", err)
+	case len(addrs) == 0:
+		detail = "SOA MNAME is not in the NS set and has no A or AAAA record"
+	}
+	d.Findings.Add(SEVERITY_INFO, CATEGORY_DELEGATION, d.Primary, detail)
+}
+
+func (d *DelegationAudit) Print() {
+	color.Blue("[ Delegation ]")
+	fmt.Printf("  | \tParent: %s\n", d.Parent)
+	fmt.Printf("  | \tParent NS: %s\n", orDash(strings.Join(d.ParentNS, ", ")))
+	fmt.Printf("  | \tZone NS: %s\n", orDash(strings.Join(d.ChildNS, ", ")))
+	fmt.Printf("  | \tSOA MNAME: %s\n", orDash(d.Primary))
+	for i, check := range d.Servers {
+		line := fmt.Sprintf("%s (%s)\t%s", check.Name, check.Address, check.Status)
+		if !check.Authoritative() {
+			line = color.YellowString(line)
+		}
+		if i == len(d.Servers)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+	color.Blue("[ Delegation Findings ]")
+	d.Findings.Print()
+}
+
+// resolveAddrs returns the A and AAAA addresses of a host through the resolver.
+func resolveAddrs(host, nameserver string) []string {
+	addrs, _ := resolveHost(host, nameserver)
+	return addrs
+}
+
+// resolveHost is resolveAddrs that also reports a failed query. NXDOMAIN and
+// NODATA are answers, timeouts and other rcodes such as SERVFAIL are errors.
+func resolveHost(host, nameserver string) ([]string, error) {
+	addrs := make([]string, 0)
+	var failed error
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		in, err := exchange(dns.Fqdn(host), qtype, nameserver)
+		if err == nil && in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+			err = fmt.Errorf("%s %s", dns.TypeToString[qtype], dns.RcodeToString[in.Rcode])
+		}
+		if err != nil {
+			failed = err
+			continue
+		}
+		for _, rr := range in.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, v.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, v.AAAA.String())
+			}
+		}
+	}
+	return addrs, failed
+}
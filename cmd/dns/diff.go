@@ -0,0 +1,464 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	CATEGORY_CHANGE      = "Change"
+	DEFAULT_DIFF_TIMEOUT = 3 * time.Second
+)
+
+// Records rewritten on every re-sign, comparing them would bury the real changes
+var signingTypes = map[uint16]bool{
+	dns.TypeRRSIG: true,
+	dns.TypeNSEC:  true,
+	dns.TypeNSEC3: true,
+}
+
+const DIFF_START_STRING = `
+[DNS SNAPSHOT DIFF]
+ Domain: %s
+ Old: %s
+ New: %s
+ Time Start: %s
+`
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare DNS snapshots",
+	Long: `
+[DNS SNAPSHOT DIFF]
+	[-- REQUIRED --]
+	-o <Older snapshot saved with --snapshot>
+
+	[-- OPTIONAL --]
+	-N <Newer snapshot, a live run is compared when omitted>
+	-n <Nameserver for the live run, defaults to the one in the old snapshot>
+	-t <DNS Record types for the live run, defaults to the old snapshot's>
+	-T <Thread Count>
+	--snapshot <Directory to save the live run to>
+
+	[-- EXAMPLES --]
+	genum dns diff -o snapshots/example.com_20240101T120000.json -N snapshots/example.com_20240401T120000.json
+	genum dns diff -o snapshots/example.com_20240101T120000.json -t ANY --snapshot snapshots/
+`,
+	PreRunE: validateDiff,
+	RunE:    executeDiff,
+}
+
+type Diff_Options struct {
+	utils.Options
+	Old        string
+	New        string
+	Nameserver string
+	Type       string
+	Threads    int
+	Snapshot   string
+}
+
+func init() {
+	DiffCmd.Flags().StringP("old", "o", "", "older snapshot file")
+	DiffCmd.Flags().StringP("new", "N", "", "newer snapshot file, a live run is used when empty")
+	DiffCmd.Flags().StringP("nameserver", "n", "", "nameserver for the live run, defaults to the old snapshot's")
+	DiffCmd.Flags().StringP("type", "t", "", "DNS Record types for the live run, defaults to the old snapshot's")
+	DiffCmd.Flags().IntP("threads", "T", DEFAULT_THREAD_COUNT, "Thread Count: Default: 10")
+	DiffCmd.Flags().String("snapshot", "", "directory to save the live run snapshot to")
+	DNSCmd.AddCommand(DiffCmd)
+}
+
+func validateDiff(cmd *cobra.Command, args []string) error {
+	var options = new(Diff_Options)
+	err := options.AddRequired(cmd,
+		"old", &options.Old,
+	)
+	if err != nil {
+		return err
+	}
+	err = options.Add(cmd,
+		"new", &options.New,
+		"nameserver", &options.Nameserver,
+		"type", &options.Type,
+		"threads", &options.Threads,
+		"snapshot", &options.Snapshot,
+	)
+	if err != nil {
+		return err
+	}
+	cmd.SetContext(context.WithValue(cmd.Context(), Key{}, options))
+	return nil
+}
+
+func executeDiff(cmd *cobra.Command, args []string) error {
+	validatedArgs := cmd.Context().Value(Key{})
+	if validatedArgs == nil {
+		return fmt.Errorf("[Command Line Options Error]")
+	}
+	opts, ok := validatedArgs.(*Diff_Options)
+	if !ok {
+		return fmt.Errorf("Invalid Type: %T", validatedArgs)
+	}
+	old, err := LoadSnapshot(opts.Old)
+	if err != nil {
+		return err
+	}
+	start_time := time.Now()
+	source := opts.New
+	if source == "" {
+		source = "live"
+	}
+	fmt.Printf(DIFF_START_STRING, old.Domain, opts.Old, source, start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[PROGRESS]---------------------")
+
+	var current *Snapshot
+	if opts.New != "" {
+		if current, err = LoadSnapshot(opts.New); err != nil {
+			return err
+		}
+	} else {
+		if current, err = liveSnapshot(old, opts); err != nil {
+			return err
+		}
+	}
+
+	if !strings.EqualFold(dns.Fqdn(old.Domain), dns.Fqdn(current.Domain)) {
+		return fmt.Errorf("Snapshot Domain Mismatch: %s and %s", old.Domain, current.Domain)
+	}
+	diff := DiffSnapshots(old, current)
+	diff.Print()
+
+	end_time := time.Now()
+	fmt.Printf(DNS_END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	return nil
+}
+
+// liveSnapshot enumerates the old snapshot's domain again with the stages it
+// recorded, transferring the zone whenever the old snapshot held transfers.
+func liveSnapshot(old *Snapshot, opts *Diff_Options) (*Snapshot, error) {
+	live := &DNS_Options{
+		Nameserver:  opts.Nameserver,
+		Type:        opts.Type,
+		Threads:     opts.Threads,
+		Time:        utils.Duration(DEFAULT_DIFF_TIMEOUT),
+		AXFRWorkers: DEFAULT_AXFR_WORKERS,
+		AXFRDepth:   DEFAULT_AXFR_DEPTH,
+		Snapshot:    opts.Snapshot,
+	}
+	if live.Nameserver == "" {
+		live.Nameserver = old.Nameserver
+	}
+	if live.Nameserver == "" {
+		live.Nameserver = DEFAULT_NAME_SERVER
+	}
+	if old.Stages != nil {
+		old.Stages.apply(live)
+		if live.Type == "" {
+			live.Type = old.Stages.Types
+		}
+	}
+	if live.Type == "" {
+		live.Type = DEFAULT_OPTION
+	}
+	types := parseTypes(live.Type)
+	if len(old.Transfers) > 0 && !slices.Contains(types, dns.TypeAXFR) {
+		types = append(types, dns.TypeAXFR)
+	}
+	report := runDomain(live, old.Domain, types, nil, "", "")
+	if report.Err != nil {
+		return nil, report.Err
+	}
+	if report.SnapshotFile != "" {
+		fmt.Printf("[SNAPSHOT] %s\n", report.SnapshotFile)
+	}
+	return NewSnapshot(report, live), nil
+}
+
+type rrsetKey struct {
+	Name string
+	Type uint16
+}
+
+type RRsetChange struct {
+	Name    string
+	Type    uint16
+	Removed []string
+	Added   []string
+}
+
+// SnapshotDiff holds the record, host and transfer changes between two snapshots.
+type SnapshotDiff struct {
+	Old       *Snapshot
+	New       *Snapshot
+	Added     []string
+	Removed   []string
+	Changed   []RRsetChange
+	NewHosts  []string
+	GoneHosts []string
+	// RecordHosts is set when the snapshots ran different stages and only
+	// hosts owning records were compared
+	RecordHosts bool
+	// Signing counts the RRSIG, NSEC and NSEC3 records added or removed
+	Signing  int
+	Findings *Findings
+}
+
+// rrsets groups records by owner and type. Records are compared without their
+// TTL, the displayed form keeps it. Signing records are left out.
+func rrsets(s *Snapshot) map[rrsetKey]map[string]string {
+	sets := make(map[rrsetKey]map[string]string)
+	for _, rr := range s.RRs() {
+		if signingTypes[rr.Header().Rrtype] {
+			continue
+		}
+		key := rrsetKey{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+		if sets[key] == nil {
+			sets[key] = make(map[string]string)
+		}
+		display := rr.String()
+		rr.Header().Ttl = 0
+		rr.Header().Name = key.Name
+		sets[key][rr.String()] = display
+	}
+	return sets
+}
+
+// signingRecords returns the signing records of a snapshot without their TTL.
+func signingRecords(s *Snapshot) map[string]bool {
+	records := make(map[string]bool)
+	for _, rr := range s.RRs() {
+		if signingTypes[rr.Header().Rrtype] {
+			rr.Header().Ttl = 0
+			rr.Header().Name = strings.ToLower(rr.Header().Name)
+			records[rr.String()] = true
+		}
+	}
+	return records
+}
+
+func DiffSnapshots(old, current *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{
+		Old:       old,
+		New:       current,
+		Added:     make([]string, 0),
+		Removed:   make([]string, 0),
+		Changed:   make([]RRsetChange, 0),
+		NewHosts:  make([]string, 0),
+		GoneHosts: make([]string, 0),
+		Findings:  NewFindings(),
+	}
+	before, after := rrsets(old), rrsets(current)
+
+	for key, set := range after {
+		prev, ok := before[key]
+		if !ok {
+			for _, display := range set {
+				d.Added = append(d.Added, display)
+			}
+			d.highlight(key, nil, set)
+			continue
+		}
+		change := RRsetChange{Name: key.Name, Type: key.Type}
+		for norm, display := range set {
+			if _, ok := prev[norm]; !ok {
+				change.Added = append(change.Added, display)
+			}
+		}
+		for norm, display := range prev {
+			if _, ok := set[norm]; !ok {
+				change.Removed = append(change.Removed, display)
+			}
+		}
+		if len(change.Added) == 0 && len(change.Removed) == 0 {
+			continue
+		}
+		sort.Strings(change.Added)
+		sort.Strings(change.Removed)
+		d.Changed = append(d.Changed, change)
+		d.highlight(key, prev, set)
+	}
+	for key, set := range before {
+		if _, ok := after[key]; ok {
+			continue
+		}
+		for _, display := range set {
+			d.Removed = append(d.Removed, display)
+		}
+		d.highlight(key, set, nil)
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		if d.Changed[i].Name != d.Changed[j].Name {
+			return d.Changed[i].Name < d.Changed[j].Name
+		}
+		return d.Changed[i].Type < d.Changed[j].Type
+	})
+
+	d.RecordHosts = !sameStages(old.Stages, current.Stages)
+	oldHosts, newHosts := d.hosts(old, before), d.hosts(current, after)
+	for _, host := range newHosts {
+		if !slices.Contains(oldHosts, host) {
+			d.NewHosts = append(d.NewHosts, host)
+		}
+	}
+	for _, host := range oldHosts {
+		if !slices.Contains(newHosts, host) {
+			d.GoneHosts = append(d.GoneHosts, host)
+		}
+	}
+	oldSigning, newSigning := signingRecords(old), signingRecords(current)
+	for record := range newSigning {
+		if !oldSigning[record] {
+			d.Signing++
+		}
+	}
+	for record := range oldSigning {
+		if !newSigning[record] {
+			d.Signing++
+		}
+	}
+	d.diffTransfers()
+	return d
+}
+
+// hosts returns the record owners of a snapshot and, when both snapshots ran
+// the same stages, the hosts those stages discovered.
+func (d *SnapshotDiff) hosts(s *Snapshot, sets map[rrsetKey]map[string]string) []string {
+	hosts := make([]string, 0, len(s.Hosts)+len(sets))
+	if !d.RecordHosts {
+		for _, host := range s.Hosts {
+			hosts = append(hosts, dns.Fqdn(strings.ToLower(host)))
+		}
+	}
+	for key := range sets {
+		hosts = append(hosts, key.Name)
+	}
+	sort.Strings(hosts)
+	return slices.Compact(hosts)
+}
+
+// highlight flags the changes worth a second look: delegation, mail routing and the SOA serial.
+func (d *SnapshotDiff) highlight(key rrsetKey, before, after map[string]string) {
+	switch key.Type {
+	case dns.TypeNS, dns.TypeMX:
+		// A set that was never queried before is new coverage, not a change
+		if before == nil {
+			return
+		}
+		d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_CHANGE, key.Name, fmt.Sprintf("%s set changed: [%s] -> [%s]",
+			dns.TypeToString[key.Type], rdataList(before), rdataList(after)))
+	case dns.TypeSOA:
+		oldSerial, newSerial := soaSerial(before), soaSerial(after)
+		if oldSerial == 0 || newSerial == 0 || oldSerial == newSerial {
+			return
+		}
+		delta := int64(newSerial) - int64(oldSerial)
+		severity := SEVERITY_INFO
+		detail := fmt.Sprintf("SOA serial %d -> %d (%+d)", oldSerial, newSerial, delta)
+		if delta < 0 {
+			severity, detail = SEVERITY_MEDIUM, detail+", serial went backwards"
+		}
+		d.Findings.Add(severity, CATEGORY_CHANGE, key.Name, detail)
+	}
+}
+
+func (d *SnapshotDiff) diffTransfers() {
+	for key := range d.New.Transfers {
+		if _, ok := d.Old.Transfers[key]; !ok {
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_CHANGE, key, "zone transfer is now allowed")
+		}
+	}
+	for key := range d.Old.Transfers {
+		if _, ok := d.New.Transfers[key]; !ok {
+			d.Findings.Add(SEVERITY_INFO, CATEGORY_CHANGE, key, "zone transfer no longer allowed")
+		}
+	}
+}
+
+func rdataList(set map[string]string) string {
+	out := make([]string, 0, len(set))
+	for norm := range set {
+		if rr, err := dns.NewRR(norm); err == nil && rr != nil {
+			out = append(out, strings.TrimPrefix(rr.String(), rr.Header().String()))
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+func soaSerial(set map[string]string) uint32 {
+	for norm := range set {
+		if rr, err := dns.NewRR(norm); err == nil {
+			if soa, ok := rr.(*dns.SOA); ok {
+				return soa.Serial
+			}
+		}
+	}
+	return 0
+}
+
+func (d *SnapshotDiff) Print() {
+	color.Blue("[ Snapshot Diff ]")
+	fmt.Printf("  %s -> %s\n", d.Old.Time.Format(TIME_FORMAT), d.New.Time.Format(TIME_FORMAT))
+	fmt.Printf("  Added: %d | Removed: %d | Changed: %d | New Hosts: %d | Gone Hosts: %d\n\n",
+		len(d.Added), len(d.Removed), len(d.Changed), len(d.NewHosts), len(d.GoneHosts))
+	if d.Signing > 0 {
+		fmt.Printf("  Signing records (RRSIG, NSEC, NSEC3) changed: %d, not listed\n\n", d.Signing)
+	}
+	if d.RecordHosts {
+		color.Yellow("  Snapshots ran different stages, only hosts owning records are compared\n\n")
+	}
+
+	color.Blue("[ Highlights ]")
+	d.Findings.Print()
+	if len(d.NewHosts) > 0 {
+		color.Blue("[ New Hosts ]")
+		printTree(d.NewHosts, "+ ")
+	}
+	if len(d.GoneHosts) > 0 {
+		color.Blue("[ Gone Hosts ]")
+		printTree(d.GoneHosts, "- ")
+	}
+	if len(d.Added) > 0 {
+		color.Blue("[ Added Records ]")
+		printTree(d.Added, "+ ")
+	}
+	if len(d.Removed) > 0 {
+		color.Blue("[ Removed Records ]")
+		printTree(d.Removed, "- ")
+	}
+	if len(d.Changed) > 0 {
+		color.Blue("[ Changed Records ]")
+		for _, change := range d.Changed {
+			color.Yellow("  [ %s %s ]", change.Name, dns.TypeToString[change.Type])
+			lines := make([]string, 0, len(change.Removed)+len(change.Added))
+			for _, rr := range change.Removed {
+				lines = append(lines, "- "+rr)
+			}
+			for _, rr := range change.Added {
+				lines = append(lines, "+ "+rr)
+			}
+			printTree(lines, "")
+		}
+	}
+}
+
+func printTree(lines []string, prefix string) {
+	for i, line := range lines {
+		if i == len(lines)-1 {
+			fmt.Printf("  |_____%s%s\n\n", prefix, line)
+			break
+		}
+		fmt.Printf("  | \t%s%s\n", prefix, line)
+	}
+}
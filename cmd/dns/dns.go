@@ -11,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	// "github.com/fatih/color"
 	"github.com/miekg/dns"
 	"github.com/phaze228/genum/utils"
 	"github.com/spf13/cobra"
@@ -25,6 +24,11 @@ const (
 	DEFAULT_DNS_PORT    = 53
 	DEFAULT_NAME_SERVER = "8.8.8.8" // Googles DNS
 	DEFAULT_OPTION      = "ANY"
+	//		AXFR
+	DEFAULT_AXFR_WORKERS = 5
+	DEFAULT_AXFR_DEPTH   = 0
+	//		Batch
+	DEFAULT_DOMAIN_WORKERS = 4
 )
 
 var (
@@ -51,17 +55,52 @@ var DNSCmd = &cobra.Command{
 	Long: `
 [DNS Enumeration]
 	[-- REQUIRED --]
-	-d <Domain to query DNS records>
+	-d <Domain, comma separated domains or file of domains to query DNS records>
 
 	[-- OPTIONAL --]
 	-n <Nameserver to resolve DNS queries>
 	-t <DNS Record type>
 	-T <Thread Count>
-	-d <Timeout Duration>
+	-D <Timeout Duration>
 	-p <Port for service>
+	-e <Expand discovered targets into a host inventory>
+	-P <Resolve permutations of discovered names>
+	-w <Word or file of words for permutations>
+	--rounds <Permutation rounds>
+	-b <Word or file of words to brute force subdomains with>
+	--rate <Initial brute force queries per second>
+	--sockets <UDP sockets used for brute forcing>
+	--axfr-workers <Concurrent zone transfers>
+	--axfr-depth <Recursion depth for nested zone transfers, 0 is unlimited>
+	--dnssec <Validate the DNSSEC chain of trust and audit the zone signing>
+	--delegation <Check for lame delegations, parent/zone NS mismatches and a hidden primary>
+	--dangling <Look for NS hosts under unregistered domains or providers no longer hosting the zone>
+	--ad <Find Active Directory domain controllers from SRV records and CLDAP ping them>
+	--dnssd <Walk unicast DNS-SD browse domains, service types and instances>
+	--siblings <Try the base label under other public suffixes and compare NS, MX and SOA RNAME, candidates are kept in --snapshot>
+	--suffixes <Suffix or file of suffixes for --siblings>
+	--ecs <Repeat A/AAAA/CNAME queries with EDNS Client Subnets>
+	--ecs-prefixes <Prefix or file of prefixes for --ecs>
+	--lb <Repeat A/AAAA queries over rounds and resolvers to find round-robin and GSLB/CDN hosts>
+	--lb-rounds <Query rounds for --lb>
+	--lb-interval <Pause between --lb rounds>
+	--resolvers <Resolver or file of resolvers queried alongside the nameserver for --lb>
+	--mmdb <MaxMind format database or list of databases for offline enrichment>
+	--ip2asn <ip2asn TSV file for offline enrichment>
+	--graph <Export a relationship graph: .dot or .graphml>
+	--emails <Write harvested addresses of the domain to a file for smtp -U>
+	--domain-workers <Domains enumerated concurrently>
+	--certs <Harvest SAN and CN names from TLS certificates of resolved hosts>
+	--cert-ports <Ports to grab certificates from, 25 and 587 use STARTTLS>
+	--snapshot <Directory to save a timestamped JSON snapshot of the results to>
 
 	[-- EXAMPLES --]
 	goEnum dns -d zonetransfer.me -n nsztm1.digi.ninja. -t AXFR
+	goEnum dns -d domains.txt --domain-workers 8 -t NS,SOA,MX,AXFR
+	goEnum dns -d example.com --snapshot snapshots/
+	goEnum dns -d example.com -t A,MX -e --certs -D 5s
+	goEnum dns -d corp.local -n 10.0.0.10 --ad
+	goEnum dns -d example.com -t AXFR --lb --lb-rounds 10
 `,
 	PreRunE: validateDNS,
 	RunE:    executeDNS,
@@ -69,21 +108,52 @@ var DNSCmd = &cobra.Command{
 
 type DNS_Options struct {
 	utils.Options
-	Domain     string
-	Nameserver string
-	Type       string
-	Port       int
-	Threads    int
-	Time       utils.Duration
-	Verbose    bool
-	SSL        bool
+	Domain        string
+	Nameserver    string
+	Type          string
+	Port          int
+	Threads       int
+	Time          utils.Duration
+	Verbose       bool
+	SSL           bool
+	Expand        bool
+	Permute       bool
+	Wordlist      string
+	Rounds        int
+	Brute         string
+	Rate          int
+	Sockets       int
+	AXFRWorkers   int
+	AXFRDepth     int
+	DNSSEC        bool
+	Delegation    bool
+	Dangling      bool
+	AD            bool
+	DNSSD         bool
+	Siblings      bool
+	Suffixes      string
+	Emails        string
+	ECS           bool
+	ECSPrefixes   string
+	LB            bool
+	LBRounds      int
+	LBInterval    utils.Duration
+	Resolvers     string
+	MMDB          string
+	IP2ASN        string
+	Graph         string
+	DomainWorkers int
+	Snapshot      string
+	Certs         bool
+	CertPorts     string
 }
 
 type Key struct{}
 
 func init() {
 	var duration utils.Duration = utils.Duration(time.Duration(3) * time.Second)
-	DNSCmd.Flags().StringP("domain", "d", "", "domain name to check DNS of")
+	var lbInterval utils.Duration = utils.Duration(DEFAULT_LB_INTERVAL)
+	DNSCmd.Flags().StringP("domain", "d", "", "domain, comma separated domains or file of domains to check DNS of")
 	DNSCmd.Flags().StringP("nameserver", "n", DEFAULT_NAME_SERVER, "nameserver to resolve queries")
 	DNSCmd.Flags().StringP("type", "t", DEFAULT_OPTION, "DNS Enumeration Modes: [ANY, AXFR, A, AAAA... etc,]")
 	// dnsCmd.Flags().StringP("domain", "D", "", "Domain to append to usernames: user@domain.com")
@@ -93,6 +163,36 @@ func init() {
 	DNSCmd.Flags().VarP(&duration, "duration", "D", "Timeout: 3s, 10s...etc")
 	DNSCmd.Flags().BoolP("verbose", "v", false, "Verbose output, prints nearly everything")
 	DNSCmd.Flags().BoolP("ssl", "S", false, "Enable SSL")
+	DNSCmd.Flags().BoolP("expand", "e", false, "Resolve CNAME chains and MX/NS/SRV targets into a host inventory")
+	DNSCmd.Flags().BoolP("permute", "P", false, "Resolve altdns style permutations of discovered names")
+	DNSCmd.Flags().StringP("wordlist", "w", "", "word, comma separated words or file of words used for permutations")
+	DNSCmd.Flags().Int("rounds", DEFAULT_PERMUTE_ROUNDS, "Permutation rounds fed back from previous hits")
+	DNSCmd.Flags().StringP("brute", "b", "", "word or file of words to brute force subdomains with")
+	DNSCmd.Flags().Int("rate", DEFAULT_ENGINE_RATE, "Initial queries per second, adapted to observed loss")
+	DNSCmd.Flags().Int("sockets", DEFAULT_ENGINE_SOCKETS, "UDP sockets kept open for brute forcing")
+	DNSCmd.Flags().Int("axfr-workers", DEFAULT_AXFR_WORKERS, "Concurrent zone transfers")
+	DNSCmd.Flags().Int("axfr-depth", DEFAULT_AXFR_DEPTH, "Recursion depth for nested zone transfers, 0 is unlimited")
+	DNSCmd.Flags().Bool("dnssec", false, "Validate the DNSSEC chain of trust and audit the zone signing")
+	DNSCmd.Flags().Bool("delegation", false, "Check every NS answers authoritatively, compare parent and zone NS sets and look for a hidden primary")
+	DNSCmd.Flags().Bool("dangling", false, "Flag NS hosts under unregistered domains and DNS providers that no longer host the zone")
+	DNSCmd.Flags().Bool("ad", false, "Discover Active Directory domain controllers via SRV records and query them with a CLDAP NetLogon ping")
+	DNSCmd.Flags().Bool("dnssd", false, "Walk unicast DNS-SD browse domains and resolve the PTR, SRV and TXT records of every advertised service")
+	DNSCmd.Flags().Bool("siblings", false, "Check the base label across public suffixes and ccTLDs and flag siblings sharing NS, MX or SOA RNAME")
+	DNSCmd.Flags().String("suffixes", "", "suffix, comma separated suffixes or file of suffixes for --siblings")
+	DNSCmd.Flags().Bool("ecs", false, "Repeat A/AAAA/CNAME queries for discovered names with EDNS Client Subnets")
+	DNSCmd.Flags().String("ecs-prefixes", "", "prefix, comma separated prefixes or file of prefixes for --ecs")
+	DNSCmd.Flags().Bool("lb", false, "Repeat A/AAAA queries for discovered names over several rounds and resolvers and classify them as static, round-robin or GSLB/CDN")
+	DNSCmd.Flags().Int("lb-rounds", DEFAULT_LB_ROUNDS, "Query rounds for --lb")
+	DNSCmd.Flags().Var(&lbInterval, "lb-interval", "Pause between --lb rounds: 1s, 5s...etc")
+	DNSCmd.Flags().String("resolvers", "", "resolver, comma separated resolvers or file of resolvers for --lb: Default: 8.8.8.8,1.1.1.1,9.9.9.9,208.67.222.222")
+	DNSCmd.Flags().String("mmdb", "", "MaxMind format database, comma separated databases or file listing them")
+	DNSCmd.Flags().String("ip2asn", "", "ip2asn TSV file used to enrich resolved addresses offline")
+	DNSCmd.Flags().String("graph", "", "file to export the relationship graph to, GraphML for .graphml and DOT otherwise")
+	DNSCmd.Flags().String("emails", "", "file to write addresses harvested from SOA, RP, TXT and CAA records to, one per line for smtp -U")
+	DNSCmd.Flags().Int("domain-workers", DEFAULT_DOMAIN_WORKERS, "Domains enumerated concurrently in batch mode")
+	DNSCmd.Flags().Bool("certs", false, "Harvest SAN and CN names from TLS certificates and resolve the in-scope ones")
+	DNSCmd.Flags().String("cert-ports", "", "comma separated ports to grab certificates from: Default: 443,465,993,995,636,8443,25,587")
+	DNSCmd.Flags().String("snapshot", "", "directory to save a timestamped JSON snapshot of records and transfers to")
 }
 
 func validateDNS(cmd *cobra.Command, args []string) error {
@@ -111,6 +211,37 @@ func validateDNS(cmd *cobra.Command, args []string) error {
 		"threads", &options.Threads,
 		"verbose", &options.Verbose,
 		"ssl", &options.SSL,
+		"expand", &options.Expand,
+		"permute", &options.Permute,
+		"wordlist", &options.Wordlist,
+		"rounds", &options.Rounds,
+		"brute", &options.Brute,
+		"rate", &options.Rate,
+		"sockets", &options.Sockets,
+		"axfr-workers", &options.AXFRWorkers,
+		"axfr-depth", &options.AXFRDepth,
+		"dnssec", &options.DNSSEC,
+		"delegation", &options.Delegation,
+		"dangling", &options.Dangling,
+		"ad", &options.AD,
+		"dnssd", &options.DNSSD,
+		"siblings", &options.Siblings,
+		"suffixes", &options.Suffixes,
+		"emails", &options.Emails,
+		"ecs", &options.ECS,
+		"ecs-prefixes", &options.ECSPrefixes,
+		"lb", &options.LB,
+		"lb-rounds", &options.LBRounds,
+		"lb-interval", &options.LBInterval,
+		"resolvers", &options.Resolvers,
+		"mmdb", &options.MMDB,
+		"ip2asn", &options.IP2ASN,
+		"graph", &options.Graph,
+		"domain-workers", &options.DomainWorkers,
+		"snapshot", &options.Snapshot,
+		"certs", &options.Certs,
+		"cert-ports", &options.CertPorts,
+		"duration", &options.Time,
 	)
 	if err != nil {
 		return err
@@ -131,28 +262,61 @@ func executeDNS(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Invalid Type: %T", validatedArgs)
 
 	}
-	start_time := time.Now()
-	fmt.Printf(DNS_START_STRING, opts.Domain, opts.Nameserver, start_time.Format(TIME_FORMAT))
-	domain := dns.Fqdn(opts.Domain)
-	ns := opts.Nameserver
-	recordTypes := func() []uint16 {
-		buf := make([]uint16, 0)
-		test := strings.Split(opts.Type, ",")
-		for _, t := range test {
-			buf = append(buf, dns.StringToType[strings.TrimSpace(t)])
+	domains := make([]string, 0)
+	for _, d := range splitList(opts.Domain) {
+		d = dns.Fqdn(strings.ToLower(d))
+		if !slices.Contains(domains, d) {
+			domains = append(domains, d)
 		}
-		return buf
-	}()
-	fmt.Println("\n------------[PROGRESS]---------------------")
-	recs := NewRecords()
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("No domains to enumerate: %s", opts.Domain)
+	}
+	recordTypes := parseTypes(opts.Type)
+	var enricher *Enricher
+	if opts.MMDB != "" || opts.IP2ASN != "" {
+		var err error
+		enricher, err = NewEnricher(splitList(opts.MMDB), opts.IP2ASN)
+		if err != nil {
+			return err
+		}
+		defer enricher.Close()
+	}
 
-	if slices.Contains(recordTypes, dns.TypeANY) {
-		recs.CheckAllRecords(domain, ns, DNSRecTypes[:])
-	} else {
-		recs.CheckAllRecords(domain, ns, recordTypes)
+	start_time := time.Now()
+	fmt.Printf(DNS_START_STRING, strings.Join(domains, ", "), opts.Nameserver, start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[PROGRESS]---------------------")
+	reports := runBatch(opts, domains, recordTypes, enricher)
+	if len(reports) > 1 {
+		fmt.Println()
+		PrintRollup(reports)
 	}
 	end_time := time.Now()
 	fmt.Printf(DNS_END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	if len(reports) == 1 {
+		return reports[0].Err
+	}
 	return nil
+}
 
+// parseTypes turns a comma separated list of record types into their codes, ANY being every type.
+func parseTypes(value string) []uint16 {
+	buf := make([]uint16, 0)
+	for _, t := range strings.Split(value, ",") {
+		buf = append(buf, dns.StringToType[strings.ToUpper(strings.TrimSpace(t))])
+	}
+	if slices.Contains(buf, dns.TypeANY) {
+		return DNSRecTypes[:]
+	}
+	return buf
+}
+
+// splitList expands a file, a single value or a comma separated list into its entries.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	entries := make([]string, 0)
+	utils.AppendFileContentsOrString(value, &entries)
+	return slices.DeleteFunc(strings.Split(strings.Join(entries, ","), ","), func(e string) bool { return e == "" })
 }
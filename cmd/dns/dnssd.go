@@ -0,0 +1,201 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+const DNSSD_META = "_services._dns-sd._udp"
+
+// Browse domain pointers (RFC 6763 section 11): browse, default browse,
+// legacy browse, registration and default registration
+var sdBrowsePointers = []string{
+	"b._dns-sd._udp",
+	"db._dns-sd._udp",
+	"lb._dns-sd._udp",
+	"r._dns-sd._udp",
+	"dr._dns-sd._udp",
+}
+
+// Service types asked for directly, zones often skip the meta PTR
+var sdServiceTypes = []string{
+	"_ipp._tcp",
+	"_ipps._tcp",
+	"_printer._tcp",
+	"_pdl-datastream._tcp",
+	"_scanner._tcp",
+	"_uscan._tcp",
+	"_airplay._tcp",
+	"_raop._tcp",
+	"_googlecast._tcp",
+	"_smb._tcp",
+	"_afpovertcp._tcp",
+	"_nfs._tcp",
+	"_webdav._tcp",
+	"_http._tcp",
+	"_https._tcp",
+	"_ssh._tcp",
+	"_sftp-ssh._tcp",
+	"_rfb._tcp",
+	"_device-info._tcp",
+	"_workstation._tcp",
+	"_homekit._tcp",
+}
+
+type SDInstance struct {
+	Name   string
+	Type   string
+	Target string
+	Port   uint16
+	TXT    []string
+	Addrs  []string
+}
+
+// DNSSDBrowser walks unicast DNS-SD: browse domains, service types and the
+// PTR, SRV and TXT records of every advertised instance.
+type DNSSDBrowser struct {
+	Domain        string
+	Nameserver    string
+	BrowseDomains []string
+	Types         []string
+	Instances     []*SDInstance
+}
+
+func NewDNSSDBrowser(domain, nameserver string) *DNSSDBrowser {
+	domain = dns.Fqdn(strings.ToLower(domain))
+	return &DNSSDBrowser{
+		Domain:        domain,
+		Nameserver:    nameserver,
+		BrowseDomains: []string{domain},
+		Types:         make([]string, 0),
+		Instances:     make([]*SDInstance, 0),
+	}
+}
+
+func (s *DNSSDBrowser) Run() {
+	for _, pointer := range sdBrowsePointers {
+		for _, target := range s.ptr(pointer + "." + s.Domain) {
+			target = strings.ToLower(target)
+			if !slices.Contains(s.BrowseDomains, target) {
+				s.BrowseDomains = append(s.BrowseDomains, target)
+			}
+		}
+	}
+	for _, browse := range s.BrowseDomains {
+		types := make([]string, 0)
+		for _, serviceType := range s.ptr(DNSSD_META + "." + browse) {
+			types = append(types, strings.ToLower(serviceType))
+		}
+		for _, serviceType := range sdServiceTypes {
+			types = append(types, serviceType+"."+browse)
+		}
+		for _, serviceType := range types {
+			if slices.Contains(s.Types, serviceType) {
+				continue
+			}
+			instances := s.ptr(serviceType)
+			if len(instances) == 0 {
+				continue
+			}
+			s.Types = append(s.Types, serviceType)
+			for _, instance := range instances {
+				s.Instances = append(s.Instances, s.resolve(instance, serviceType))
+			}
+		}
+	}
+	sort.Strings(s.Types)
+}
+
+// ptr returns the PTR targets owned by name, instance names keep their case.
+func (s *DNSSDBrowser) ptr(name string) []string {
+	targets := make([]string, 0)
+	rrs, err := lookup(name, dns.TypePTR, s.Nameserver)
+	if err != nil {
+		return targets
+	}
+	for _, rr := range rrs {
+		if ptr, ok := rr.(*dns.PTR); ok && strings.EqualFold(ptr.Hdr.Name, name) {
+			targets = append(targets, ptr.Ptr)
+		}
+	}
+	sort.Strings(targets)
+	return slices.Compact(targets)
+}
+
+func (s *DNSSDBrowser) resolve(name, serviceType string) *SDInstance {
+	instance := &SDInstance{Name: name, Type: serviceType, TXT: make([]string, 0), Addrs: make([]string, 0)}
+	if rrs, err := lookup(name, dns.TypeSRV, s.Nameserver); err == nil {
+		for _, rr := range rrs {
+			if srv, ok := rr.(*dns.SRV); ok {
+				instance.Target, instance.Port = strings.ToLower(srv.Target), srv.Port
+				break
+			}
+		}
+	}
+	if rrs, err := lookup(name, dns.TypeTXT, s.Nameserver); err == nil {
+		for _, rr := range rrs {
+			if txt, ok := rr.(*dns.TXT); ok {
+				for _, entry := range txt.Txt {
+					if entry != "" {
+						instance.TXT = append(instance.TXT, entry)
+					}
+				}
+			}
+		}
+	}
+	if instance.Target != "" && instance.Target != "." {
+		instance.Addrs = resolveAddrs(instance.Target, s.Nameserver)
+	}
+	return instance
+}
+
+// Names returns the SRV targets under the domain, instance names are labels and not hosts.
+func (s *DNSSDBrowser) Names() []string {
+	names := make([]string, 0)
+	for _, instance := range s.Instances {
+		if instance.Target != "" && dns.IsSubDomain(s.Domain, instance.Target) {
+			names = append(names, instance.Target)
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+func (s *DNSSDBrowser) Addresses() map[string][]string {
+	addresses := make(map[string][]string)
+	for _, instance := range s.Instances {
+		for _, addr := range instance.Addrs {
+			addNamed(addresses, addr, instance.Target)
+		}
+	}
+	return addresses
+}
+
+func (s *DNSSDBrowser) Print() {
+	color.Blue("[ DNS-SD Services ]")
+	fmt.Printf("  | \tBrowse Domains: %s\n", strings.Join(s.BrowseDomains, ", "))
+	fmt.Printf("  |_____Service Types: %s\n\n", orDash(strings.Join(s.Types, ", ")))
+	for _, instance := range s.Instances {
+		target := "-"
+		if instance.Target != "" {
+			target = fmt.Sprintf("%s:%d", instance.Target, instance.Port)
+		}
+		color.Yellow("  [ %s ] %s %s", instance.Name, target, strings.Join(instance.Addrs, ", "))
+		lines := slices.Clone(instance.TXT)
+		if len(lines) == 0 {
+			lines = append(lines, "-")
+		}
+		for i, line := range lines {
+			if i == len(lines)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package dns
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+const (
+	DEFAULT_SIG_WARNING = 7 * 24 * time.Hour
+	MIN_RSA_KEY_BITS    = 2048
+	CATEGORY_DNSSEC     = "DNSSEC"
+)
+
+// IANA root zone trust anchors (KSK-2017 and KSK-2024)
+var rootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+var weakAlgorithms = map[uint8]bool{
+	dns.RSAMD5:           true,
+	dns.DSA:              true,
+	dns.RSASHA1:          true,
+	dns.DSANSEC3SHA1:     true,
+	dns.RSASHA1NSEC3SHA1: true,
+	dns.ECCGOST:          true,
+}
+
+var rsaAlgorithms = map[uint8]bool{
+	dns.RSAMD5:           true,
+	dns.RSASHA1:          true,
+	dns.RSASHA1NSEC3SHA1: true,
+	dns.RSASHA256:        true,
+	dns.RSASHA512:        true,
+}
+
+// RRsets of the target zone whose signatures get checked
+var signedTypes = [...]uint16{
+	dns.TypeSOA,
+	dns.TypeNS,
+	dns.TypeDNSKEY,
+	dns.TypeA,
+	dns.TypeAAAA,
+	dns.TypeMX,
+	dns.TypeTXT,
+}
+
+type ChainLink struct {
+	Zone   string
+	Status string
+	Keys   []uint16
+}
+
+// DNSSECAudit validates the chain of trust from the root down to a domain and
+// reviews how the zone itself is signed.
+type DNSSECAudit struct {
+	Domain     string
+	Nameserver string
+	Warning    time.Duration
+	Anchors    []string
+	Chain      []ChainLink
+	Findings   *Findings
+	Zone       string
+	zoneKeys   []*dns.DNSKEY
+	apex       string
+}
+
+func NewDNSSECAudit(domain, nameserver string) *DNSSECAudit {
+	return &DNSSECAudit{
+		Domain:     dns.Fqdn(strings.ToLower(domain)),
+		Nameserver: nameserver,
+		Warning:    DEFAULT_SIG_WARNING,
+		Anchors:    rootAnchors,
+		Chain:      make([]ChainLink, 0),
+		Findings:   NewFindings(),
+	}
+}
+
+func (d *DNSSECAudit) Run() {
+	d.Zone = d.enclosingZone(d.Domain)
+	d.validateChain()
+	// Only audit the RRsets when the chain ended in a signed zone holding the domain
+	if len(d.zoneKeys) == 0 || d.apex != d.Zone {
+		return
+	}
+	d.checkSignatures()
+	d.checkDenial()
+}
+
+func (d *DNSSECAudit) Print() {
+	color.Blue("[ DNSSEC Chain of Trust ]")
+	for i, link := range d.Chain {
+		tags := make([]string, 0, len(link.Keys))
+		for _, tag := range link.Keys {
+			tags = append(tags, fmt.Sprint(tag))
+		}
+		line := fmt.Sprintf("%s\t%s", link.Zone, link.Status)
+		if len(tags) > 0 {
+			line = fmt.Sprintf("%s\t[keys: %s]", line, strings.Join(tags, ", "))
+		}
+		if i == len(d.Chain)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+	color.Blue("[ DNSSEC Findings ]")
+	d.Findings.Print()
+}
+
+// Status is the validation state of the deepest zone reached in the chain.
+func (d *DNSSECAudit) Status() string {
+	if len(d.Chain) == 0 {
+		return "unknown"
+	}
+	return d.Chain[len(d.Chain)-1].Status
+}
+
+// zoneCuts lists the root and every parent of the domain, top down.
+func zoneCuts(domain string) []string {
+	labels := dns.SplitDomainName(domain)
+	cuts := []string{"."}
+	for i := len(labels) - 1; i >= 0; i-- {
+		cuts = append(cuts, dns.Fqdn(strings.Join(labels[i:], ".")))
+	}
+	return cuts
+}
+
+func (d *DNSSECAudit) validateChain() {
+	trusted := make([]*dns.DS, 0)
+	for _, anchor := range d.Anchors {
+		if rr, err := dns.NewRR(anchor); err == nil {
+			trusted = append(trusted, rr.(*dns.DS))
+		}
+	}
+	var parentKeys []*dns.DNSKEY
+	secure := true
+
+	for _, zone := range zoneCuts(d.Zone) {
+		keys, keySigs, err := d.fetch(zone, dns.TypeDNSKEY)
+		if err != nil {
+			d.link(zone, fmt.Sprintf("error: %v", err), nil)
+			return
+		}
+		dnskeys := asDNSKEYs(keys)
+
+		if zone != "." {
+			ds, dsSigs, err := d.fetch(zone, dns.TypeDS)
+			if err != nil {
+				d.link(zone, fmt.Sprintf("error: %v", err), nil)
+				return
+			}
+			if len(ds) == 0 {
+				if len(dnskeys) == 0 {
+					// Labels that are not zone cuts stay inside the signed parent
+					if zone != d.Zone && d.enclosingZone(zone) != zone {
+						continue
+					}
+					secure = false
+					parentKeys = nil
+					d.apex, d.zoneKeys = "", nil
+					if zone == d.Zone {
+						d.link(zone, "unsigned", nil)
+						d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, zone, "zone is not signed")
+					}
+					continue
+				}
+				secure = false
+				d.link(zone, "island of security (no DS at parent)", keyTags(dnskeys))
+				d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, zone, "DNSKEY published without a DS record at the parent, chain of trust is broken")
+				d.auditKeys(zone, dnskeys)
+				d.checkExpiry(zone, dns.TypeDNSKEY, keySigs)
+				parentKeys = dnskeys
+				d.apex, d.zoneKeys = zone, dnskeys
+				continue
+			}
+			if secure && !d.verifySet(ds, dsSigs, parentKeys) {
+				secure = false
+				d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, zone, "DS RRset signature does not validate against the parent keys")
+			}
+			trusted = asDS(ds)
+			for _, record := range trusted {
+				if record.DigestType == dns.SHA1 {
+					d.Findings.Add(SEVERITY_LOW, CATEGORY_DNSSEC, zone, fmt.Sprintf("DS %d uses a SHA-1 digest", record.KeyTag))
+				}
+			}
+		}
+
+		if len(dnskeys) == 0 {
+			d.link(zone, "bogus (DS without DNSKEY)", nil)
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, zone, "parent publishes DS records but the zone serves no DNSKEY")
+			return
+		}
+		entry := matchDS(trusted, dnskeys)
+		for _, ds := range trusted {
+			if !slices.ContainsFunc(dnskeys, func(k *dns.DNSKEY) bool { return dsMatches(ds, k) }) {
+				d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, zone, fmt.Sprintf("DS %d has no matching DNSKEY", ds.KeyTag))
+			}
+		}
+		switch {
+		case len(entry) == 0:
+			secure = false
+			d.link(zone, "bogus (no DNSKEY matches DS)", keyTags(dnskeys))
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, zone, "no DNSKEY matches the DS set, validating resolvers will SERVFAIL")
+		case !d.verifySet(keys, keySigs, entry):
+			secure = false
+			d.link(zone, "bogus (DNSKEY signature invalid)", keyTags(dnskeys))
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, zone, "DNSKEY RRset is not signed by a key matching the DS set")
+		case secure:
+			d.link(zone, "secure", keyTags(dnskeys))
+		default:
+			d.link(zone, "insecure (broken above)", keyTags(dnskeys))
+		}
+		d.auditKeys(zone, dnskeys)
+		d.checkExpiry(zone, dns.TypeDNSKEY, keySigs)
+		parentKeys = dnskeys
+		d.apex, d.zoneKeys = zone, dnskeys
+	}
+}
+
+// enclosingZone returns the owner of the SOA that is authoritative for name,
+// walking up one label at a time when the answer carries none, e.g. for a CNAME.
+func (d *DNSSECAudit) enclosingZone(name string) string {
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		candidate := dns.Fqdn(strings.Join(labels[i:], "."))
+		in, err := exchangeDNSSEC(candidate, dns.TypeSOA, d.Nameserver)
+		if err != nil {
+			return name
+		}
+		for _, rr := range append(in.Answer, in.Ns...) {
+			if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, candidate) {
+				return strings.ToLower(dns.Fqdn(soa.Hdr.Name))
+			}
+		}
+	}
+	return name
+}
+
+func (d *DNSSECAudit) link(zone, status string, keys []uint16) {
+	d.Chain = append(d.Chain, ChainLink{zone, status, keys})
+}
+
+// fetch returns the RRset of qtype at name and the RRSIGs covering it.
+func (d *DNSSECAudit) fetch(name string, qtype uint16) ([]dns.RR, []*dns.RRSIG, error) {
+	in, err := exchangeDNSSEC(name, qtype, d.Nameserver)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+		return nil, nil, fmt.Errorf("%s", dns.RcodeToString[in.Rcode])
+	}
+	rrset := make([]dns.RR, 0)
+	sigs := make([]*dns.RRSIG, 0)
+	for _, rr := range in.Answer {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			if sig.TypeCovered == qtype {
+				sigs = append(sigs, sig)
+			}
+			continue
+		}
+		if rr.Header().Rrtype == qtype {
+			rrset = append(rrset, rr)
+		}
+	}
+	return rrset, sigs, nil
+}
+
+// verifySet reports whether any signature over rrset validates with one of keys.
+func (d *DNSSECAudit) verifySet(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) bool {
+	for _, sig := range sigs {
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if sig.Verify(key, rrset) == nil && sig.ValidityPeriod(time.Now()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *DNSSECAudit) auditKeys(zone string, keys []*dns.DNSKEY) {
+	for _, key := range keys {
+		role := "ZSK"
+		if key.Flags&dns.SEP != 0 {
+			role = "KSK"
+		}
+		subject := fmt.Sprintf("%s %s %d", zone, role, key.KeyTag())
+		if weakAlgorithms[key.Algorithm] {
+			d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, subject, fmt.Sprintf("weak algorithm %s", dns.AlgorithmToString[key.Algorithm]))
+		}
+		if rsaAlgorithms[key.Algorithm] {
+			if bits := rsaKeyBits(key); bits > 0 && bits < MIN_RSA_KEY_BITS {
+				d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, subject, fmt.Sprintf("%d bit RSA key", bits))
+			}
+		}
+	}
+}
+
+func (d *DNSSECAudit) checkExpiry(name string, qtype uint16, sigs []*dns.RRSIG) {
+	now := time.Now()
+	for _, sig := range sigs {
+		expires := time.Unix(int64(sig.Expiration), 0)
+		subject := fmt.Sprintf("%s RRSIG %s", name, dns.TypeToString[qtype])
+		switch {
+		case !sig.ValidityPeriod(now) && now.After(expires):
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, subject, fmt.Sprintf("signature expired %s", expires.Format(TIME_FORMAT)))
+		case !sig.ValidityPeriod(now):
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, subject, "signature is not yet valid")
+		case expires.Sub(now) < d.Warning:
+			d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, subject, fmt.Sprintf("signature expires %s", expires.Format(TIME_FORMAT)))
+		}
+	}
+}
+
+// checkSignatures makes sure the common RRsets at the domain are signed by the zone keys.
+func (d *DNSSECAudit) checkSignatures() {
+	for _, qtype := range signedTypes {
+		if qtype == dns.TypeDNSKEY && d.apex == d.Domain {
+			continue
+		}
+		rrset, sigs, err := d.fetch(d.Domain, qtype)
+		if err != nil || len(rrset) == 0 {
+			continue
+		}
+		subject := fmt.Sprintf("%s %s", d.Domain, dns.TypeToString[qtype])
+		if len(sigs) == 0 {
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, subject, "RRset in a signed zone carries no RRSIG")
+			continue
+		}
+		if !d.verifySet(rrset, sigs, d.zoneKeys) {
+			d.Findings.Add(SEVERITY_HIGH, CATEGORY_DNSSEC, subject, "no RRSIG validates against the zone keys")
+		}
+		d.checkExpiry(d.Domain, qtype, sigs)
+	}
+}
+
+// checkDenial looks at how a nonexistent name is denied to decide if the zone can be walked.
+func (d *DNSSECAudit) checkDenial() {
+	probe := randomLabel(WILDCARD_LABEL_SIZE) + "." + d.Domain
+	in, err := exchangeDNSSEC(probe, dns.TypeA, d.Nameserver)
+	if err != nil {
+		return
+	}
+	for _, rr := range in.Ns {
+		switch v := rr.(type) {
+		case *dns.NSEC:
+			d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, d.apex, fmt.Sprintf("NSEC denial allows walking the whole zone (%s -> %s)", v.Hdr.Name, v.NextDomain))
+			return
+		case *dns.NSEC3:
+			detail := fmt.Sprintf("NSEC3 with %d iterations, salt %q: hashes can be collected and cracked offline", v.Iterations, v.Salt)
+			if v.Flags&0x01 != 0 {
+				detail += ", opt-out set"
+			}
+			d.Findings.Add(SEVERITY_LOW, CATEGORY_DNSSEC, d.apex, detail)
+			return
+		}
+	}
+	if in.Rcode == dns.RcodeNameError {
+		d.Findings.Add(SEVERITY_MEDIUM, CATEGORY_DNSSEC, d.apex, "NXDOMAIN returned without NSEC or NSEC3 proof")
+	}
+}
+
+func asDNSKEYs(rrs []dns.RR) []*dns.DNSKEY {
+	keys := make([]*dns.DNSKEY, 0, len(rrs))
+	for _, rr := range rrs {
+		if key, ok := rr.(*dns.DNSKEY); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func asDS(rrs []dns.RR) []*dns.DS {
+	ds := make([]*dns.DS, 0, len(rrs))
+	for _, rr := range rrs {
+		if record, ok := rr.(*dns.DS); ok {
+			ds = append(ds, record)
+		}
+	}
+	return ds
+}
+
+func keyTags(keys []*dns.DNSKEY) []uint16 {
+	tags := make([]uint16, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, key.KeyTag())
+	}
+	return tags
+}
+
+func dsMatches(ds *dns.DS, key *dns.DNSKEY) bool {
+	if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+		return false
+	}
+	computed := key.ToDS(ds.DigestType)
+	return computed != nil && strings.EqualFold(computed.Digest, ds.Digest)
+}
+
+// matchDS returns the keys vouched for by any DS in the set.
+func matchDS(set []*dns.DS, keys []*dns.DNSKEY) []*dns.DNSKEY {
+	matched := make([]*dns.DNSKEY, 0)
+	for _, key := range keys {
+		if slices.ContainsFunc(set, func(ds *dns.DS) bool { return dsMatches(ds, key) }) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// rsaKeyBits returns the modulus size of an RFC 3110 encoded RSA key.
+func rsaKeyBits(key *dns.DNSKEY) int {
+	raw, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(raw) < 3 {
+		return 0
+	}
+	expLen, offset := int(raw[0]), 1
+	if expLen == 0 {
+		expLen, offset = int(raw[1])<<8|int(raw[2]), 3
+	}
+	modulus := raw[min(offset+expLen, len(raw)):]
+	for len(modulus) > 0 && modulus[0] == 0 {
+		modulus = modulus[1:]
+	}
+	return len(modulus) * 8
+}
@@ -0,0 +1,236 @@
+package dns
+
+import (
+	"crypto"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testKey is a zone signing key kept together with its private half.
+type testKey struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestKey(t *testing.T, zone string) *testKey {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("generate key for %s: %v", zone, err)
+	}
+	return &testKey{key, priv.(crypto.Signer)}
+}
+
+// sign returns an RRSIG over rrset valid between inception and expiration.
+func (k *testKey) sign(t *testing.T, rrset []dns.RR, inception, expiration time.Time) *dns.RRSIG {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		KeyTag:     k.key.KeyTag(),
+		SignerName: k.key.Hdr.Name,
+		Algorithm:  k.key.Algorithm,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(k.priv, rrset); err != nil {
+		t.Fatalf("sign %s: %v", rrset[0].Header().Name, err)
+	}
+	return sig
+}
+
+func testA(name, ip string) dns.RR {
+	return &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(ip)}
+}
+
+func TestVerifySet(t *testing.T) {
+	now := time.Now()
+	signer, other := newTestKey(t, "example.test."), newTestKey(t, "example.test.")
+	rrset := []dns.RR{testA("www.example.test.", "192.0.2.1")}
+	valid := signer.sign(t, rrset, now.Add(-time.Hour), now.Add(time.Hour))
+	expired := signer.sign(t, rrset, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+
+	tests := []struct {
+		name  string
+		rrset []dns.RR
+		sigs  []*dns.RRSIG
+		keys  []*dns.DNSKEY
+		want  bool
+	}{
+		{"valid signature", rrset, []*dns.RRSIG{valid}, []*dns.DNSKEY{signer.key}, true},
+		{"any key of the set", rrset, []*dns.RRSIG{valid}, []*dns.DNSKEY{other.key, signer.key}, true},
+		{"signed by another key", rrset, []*dns.RRSIG{valid}, []*dns.DNSKEY{other.key}, false},
+		{"expired signature", rrset, []*dns.RRSIG{expired}, []*dns.DNSKEY{signer.key}, false},
+		{"modified rrset", []dns.RR{testA("www.example.test.", "192.0.2.99")}, []*dns.RRSIG{valid}, []*dns.DNSKEY{signer.key}, false},
+		{"no signatures", rrset, nil, []*dns.DNSKEY{signer.key}, false},
+	}
+	audit := NewDNSSECAudit("example.test", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audit.verifySet(tt.rrset, tt.sigs, tt.keys); got != tt.want {
+				t.Errorf("verifySet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Now()
+	signer := newTestKey(t, "example.test.")
+	rrset := []dns.RR{testA("example.test.", "192.0.2.1")}
+
+	tests := []struct {
+		name       string
+		inception  time.Time
+		expiration time.Time
+		severity   string
+		detail     string
+	}{
+		{"valid for a month", now.Add(-time.Hour), now.Add(30 * 24 * time.Hour), "", ""},
+		{"expires within the warning", now.Add(-time.Hour), now.Add(2 * 24 * time.Hour), SEVERITY_MEDIUM, "signature expires"},
+		{"expired", now.Add(-48 * time.Hour), now.Add(-time.Hour), SEVERITY_HIGH, "signature expired"},
+		{"not yet valid", now.Add(time.Hour), now.Add(48 * time.Hour), SEVERITY_HIGH, "not yet valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := NewDNSSECAudit("example.test", "")
+			audit.checkExpiry("example.test.", dns.TypeA, []*dns.RRSIG{signer.sign(t, rrset, tt.inception, tt.expiration)})
+			if tt.severity == "" {
+				if audit.Findings.Len() != 0 {
+					t.Fatalf("unexpected findings: %v", audit.Findings.List)
+				}
+				return
+			}
+			if audit.Findings.Len() != 1 {
+				t.Fatalf("got %d findings, want 1: %v", audit.Findings.Len(), audit.Findings.List)
+			}
+			if got := audit.Findings.List[0]; got.Severity != tt.severity || !strings.Contains(got.Detail, tt.detail) {
+				t.Errorf("finding = %s %q, want %s %q", got.Severity, got.Detail, tt.severity, tt.detail)
+			}
+		})
+	}
+}
+
+// chainFault breaks one part of the example.test. delegation.
+type chainFault int
+
+const (
+	faultNone chainFault = iota
+	faultExpiredDNSKEY
+	faultDSMismatch
+	faultNoDNSKEY
+)
+
+// signedTree serves a signed root, test. and example.test. and behaves like a
+// validating resolver: bogus data comes back as SERVFAIL unless CD is set.
+func signedTree(t *testing.T, fault chainFault) (string, []string) {
+	t.Helper()
+	now := time.Now()
+	from, until := now.Add(-time.Hour), now.Add(30*24*time.Hour)
+	root, tld, zone := newTestKey(t, "."), newTestKey(t, "test."), newTestKey(t, "example.test.")
+	answers := make(map[dns.Question][]dns.RR)
+	publish := func(signer *testKey, rrset []dns.RR, from, until time.Time) {
+		q := dns.Question{Name: rrset[0].Header().Name, Qtype: rrset[0].Header().Rrtype, Qclass: dns.ClassINET}
+		answers[q] = append(rrset, signer.sign(t, rrset, from, until))
+	}
+
+	publish(root, []dns.RR{root.key}, from, until)
+	publish(root, []dns.RR{tld.key.ToDS(dns.SHA256)}, from, until)
+	publish(tld, []dns.RR{tld.key}, from, until)
+	delegated := zone
+	if fault == faultDSMismatch {
+		delegated = newTestKey(t, "example.test.")
+	}
+	publish(tld, []dns.RR{delegated.key.ToDS(dns.SHA256)}, from, until)
+	switch fault {
+	case faultExpiredDNSKEY:
+		publish(zone, []dns.RR{zone.key}, now.Add(-48*time.Hour), now.Add(-time.Hour))
+	case faultNoDNSKEY:
+	default:
+		publish(zone, []dns.RR{zone.key}, from, until)
+	}
+	soa := &dns.SOA{
+		Hdr: dns.RR_Header{Name: "example.test.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:  "ns.example.test.", Mbox: "hostmaster.example.test.", Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 300,
+	}
+	publish(zone, []dns.RR{soa}, from, until)
+
+	nameserver := serve(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		q.Name = strings.ToLower(q.Name)
+		switch answer, ok := answers[q]; {
+		case fault != faultNone && !r.CheckingDisabled && dns.IsSubDomain("example.test.", q.Name):
+			m.Rcode = dns.RcodeServerFailure
+		case ok:
+			m.Answer = answer
+		case q.Name != "." && q.Name != "test." && q.Name != "example.test.":
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	}))
+	return nameserver, []string{root.key.ToDS(dns.SHA256).String()}
+}
+
+func TestValidateChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  chainFault
+		status string
+		detail string
+	}{
+		{"secure", faultNone, "secure", ""},
+		{"expired DNSKEY signature", faultExpiredDNSKEY, "bogus (DNSKEY signature invalid)", "signature expired"},
+		{"DS does not match DNSKEY", faultDSMismatch, "bogus (no DNSKEY matches DS)", "no DNSKEY matches the DS set"},
+		{"DS without DNSKEY", faultNoDNSKEY, "bogus (DS without DNSKEY)", "serves no DNSKEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nameserver, anchors := signedTree(t, tt.fault)
+			audit := NewDNSSECAudit("example.test", nameserver)
+			audit.Anchors = anchors
+			audit.Run()
+
+			zones := make([]string, 0, len(audit.Chain))
+			for _, link := range audit.Chain {
+				zones = append(zones, link.Zone)
+			}
+			if want := []string{".", "test.", "example.test."}; strings.Join(zones, " ") != strings.Join(want, " ") {
+				t.Fatalf("chain = %v, want %v", zones, want)
+			}
+			for _, link := range audit.Chain[:2] {
+				if link.Status != "secure" {
+					t.Errorf("%s status = %q, want secure", link.Zone, link.Status)
+				}
+			}
+			if got := audit.Status(); got != tt.status {
+				t.Errorf("Status() = %q, want %q", got, tt.status)
+			}
+			high := make([]string, 0)
+			for _, finding := range audit.Findings.List {
+				if finding.Severity == SEVERITY_HIGH {
+					high = append(high, finding.Detail)
+				}
+			}
+			if tt.detail == "" {
+				if len(high) > 0 {
+					t.Errorf("unexpected HIGH findings: %v", high)
+				}
+				return
+			}
+			if !strings.Contains(strings.Join(high, "\n"), tt.detail) {
+				t.Errorf("HIGH findings %v do not mention %q", high, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+// Client subnets spread over several regions, used when none are given
+var defaultECSPrefixes = []string{
+	"8.8.8.0/24",      // US
+	"81.2.69.0/24",    // UK
+	"85.214.0.0/24",   // DE
+	"1.0.0.0/24",      // AU
+	"133.11.0.0/24",   // JP
+	"177.38.0.0/24",   // BR
+	"41.203.0.0/24",   // ZA
+	"103.21.244.0/24", // IN
+	"2001:4860::/56",  // US
+	"2a00:1450::/56",  // EU
+}
+
+var ecsTypes = [...]uint16{
+	dns.TypeA,
+	dns.TypeAAAA,
+	dns.TypeCNAME,
+}
+
+type ecsTask struct {
+	Name   string
+	Prefix *net.IPNet
+}
+
+type ECSAnswer struct {
+	Prefix  string
+	Scope   uint8
+	Answers []string
+}
+
+// ECSProbe repeats queries with different EDNS Client Subnets to collect the
+// per region answers a CDN or GSLB would hand out.
+type ECSProbe struct {
+	Nameserver string
+	Threads    int
+	Prefixes   []*net.IPNet
+	Results    map[string][]ECSAnswer
+	mu         sync.Mutex
+}
+
+func NewECSProbe(nameserver string, threads int, prefixes []string) (*ECSProbe, error) {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	if len(prefixes) == 0 {
+		prefixes = defaultECSPrefixes
+	}
+	probe := &ECSProbe{
+		Nameserver: nameserver,
+		Threads:    threads,
+		Prefixes:   make([]*net.IPNet, 0, len(prefixes)),
+		Results:    make(map[string][]ECSAnswer),
+	}
+	for _, p := range prefixes {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ECS Prefix: %v", err)
+		}
+		probe.Prefixes = append(probe.Prefixes, network)
+	}
+	return probe, nil
+}
+
+func (e *ECSProbe) Probe(names []string) {
+	tasks := make(chan ecsTask, 100)
+	var wg sync.WaitGroup
+
+	go func() {
+		for _, name := range names {
+			for _, prefix := range e.Prefixes {
+				tasks <- ecsTask{dns.Fqdn(strings.ToLower(name)), prefix}
+			}
+		}
+		close(tasks)
+	}()
+	for i := 0; i < e.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				answer := e.query(task)
+				e.mu.Lock()
+				e.Results[task.Name] = append(e.Results[task.Name], answer)
+				e.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (e *ECSProbe) query(task ecsTask) ECSAnswer {
+	ones, _ := task.Prefix.Mask.Size()
+	answer := ECSAnswer{Prefix: task.Prefix.String(), Answers: make([]string, 0)}
+	for _, qtype := range ecsTypes {
+		msg := new(dns.Msg)
+		msg.SetQuestion(task.Name, qtype)
+		msg.SetEdns0(4096, false)
+		subnet := &dns.EDNS0_SUBNET{
+			Code:          dns.EDNS0SUBNET,
+			Family:        1,
+			SourceNetmask: uint8(ones),
+			Address:       task.Prefix.IP,
+		}
+		if task.Prefix.IP.To4() == nil {
+			subnet.Family = 2
+		}
+		opt := msg.IsEdns0()
+		opt.Option = append(opt.Option, subnet)
+
+		in, err := exchangeMsg(msg, e.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range in.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				answer.Answers = append(answer.Answers, v.A.String())
+			case *dns.AAAA:
+				answer.Answers = append(answer.Answers, v.AAAA.String())
+			case *dns.CNAME:
+				answer.Answers = append(answer.Answers, "CNAME "+strings.ToLower(v.Target))
+			}
+		}
+		if opt := in.IsEdns0(); opt != nil {
+			for _, option := range opt.Option {
+				if returned, ok := option.(*dns.EDNS0_SUBNET); ok {
+					answer.Scope = max(answer.Scope, returned.SourceScope)
+				}
+			}
+		}
+	}
+	sort.Strings(answer.Answers)
+	answer.Answers = slices.Compact(answer.Answers)
+	return answer
+}
+
+// Distinct returns every answer seen for name across all prefixes.
+func (e *ECSProbe) Distinct(name string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	all := make([]string, 0)
+	for _, answer := range e.Results[name] {
+		all = append(all, answer.Answers...)
+	}
+	sort.Strings(all)
+	return slices.Compact(all)
+}
+
+func (e *ECSProbe) Print() {
+	e.mu.Lock()
+	names := make([]string, 0, len(e.Results))
+	for name := range e.Results {
+		names = append(names, name)
+	}
+	e.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		distinct := e.Distinct(name)
+		if len(distinct) == 0 {
+			continue
+		}
+		e.mu.Lock()
+		answers := slices.Clone(e.Results[name])
+		e.mu.Unlock()
+		sort.Slice(answers, func(i, j int) bool { return answers[i].Prefix < answers[j].Prefix })
+
+		sets := make([]string, 0, len(answers))
+		for _, answer := range answers {
+			sets = append(sets, strings.Join(answer.Answers, ","))
+		}
+		sort.Strings(sets)
+		sets = slices.Compact(sets)
+
+		scoped := slices.ContainsFunc(answers, func(a ECSAnswer) bool { return a.Scope > 0 })
+		header := fmt.Sprintf("  [ %s ] answer sets: %d | addresses: %s", name, len(sets), strings.Join(distinct, ", "))
+		if !scoped {
+			header += " (ECS ignored)"
+		}
+		if len(sets) > 1 {
+			color.Yellow(header)
+		} else {
+			fmt.Println(header)
+		}
+		for i, answer := range answers {
+			line := fmt.Sprintf("%s\tscope /%d\t%s", answer.Prefix, answer.Scope, strings.Join(answer.Answers, ", "))
+			if i == len(answers)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+}
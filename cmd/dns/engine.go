@@ -0,0 +1,462 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	//		Engine Defaults
+	DEFAULT_ENGINE_SOCKETS  = 8
+	DEFAULT_ENGINE_RATE     = 5000
+	DEFAULT_ENGINE_INFLIGHT = 20000
+	DEFAULT_ENGINE_RETRIES  = 3
+	DEFAULT_ENGINE_TIMEOUT  = 1500 * time.Millisecond
+	ENGINE_MIN_RATE         = 100
+	ENGINE_MAX_RATE         = 200000
+	ENGINE_BACKOFF          = 250 * time.Millisecond
+	ENGINE_SWEEP_INTERVAL   = 50 * time.Millisecond
+	ENGINE_BUFFER_SIZE      = 65535
+	// Loss ratio per sweep above which the send rate is cut back
+	ENGINE_LOSS_THRESHOLD = 0.02
+	ENGINE_RATE_DECREASE  = 0.7
+	ENGINE_RATE_INCREASE  = 1.05
+	// Queries in flight start at the initial window and grow while answers stay fast
+	ENGINE_INITIAL_WINDOW  = 256
+	ENGINE_MIN_WINDOW      = 16
+	ENGINE_WINDOW_INCREASE = 1.25
+	// Average RTT above the lowest one seen by this much means the server is queueing
+	ENGINE_QUEUE_DELAY = 20 * time.Millisecond
+)
+
+type Query struct {
+	Name string
+	Type uint16
+}
+
+type Result struct {
+	Query
+	Msg      *dns.Msg
+	Err      error
+	Attempts int
+	RTT      time.Duration
+}
+
+type inflight struct {
+	query     Query
+	sock      int
+	id        uint16
+	attempts  int
+	sent      time.Time
+	window    float64
+	notBefore time.Time
+	done      func()
+}
+
+// Engine is a massdns style resolver: a fixed pool of long lived UDP sockets,
+// many queries in flight matched back by ID, and a send rate that backs off
+// whenever answers stop coming back. Losses only show up a timeout later, so
+// the number of queries in flight is also held to a window that shrinks as
+// soon as answers slow down.
+type Engine struct {
+	Nameserver  string
+	Sockets     int
+	MaxInflight int
+	Retries     int
+	Timeout     time.Duration
+
+	conns    []*net.UDPConn
+	pending  []map[uint16]*inflight
+	nextID   []uint16
+	nextSock int
+	retries  []*inflight
+	slots    chan struct{}
+	out      chan Result
+	mu       sync.Mutex
+
+	rate   float64
+	tokens float64
+	last   time.Time
+	window float64
+	// Window growth turns additive above the last window that lost queries
+	threshold float64
+	minRTT    time.Duration
+	lastCut   time.Time
+
+	sent      int64
+	received  int64
+	timeouts  int64
+	resent    int64
+	sweepSent int64
+	// Answers, their summed RTT and window waits since the last sweep
+	sweepAnswers int64
+	sweepRTT     int64
+	sweepLimited int64
+}
+
+func NewEngine(nameserver string, sockets, rate int) *Engine {
+	if sockets <= 0 {
+		sockets = DEFAULT_ENGINE_SOCKETS
+	}
+	if rate <= 0 {
+		rate = DEFAULT_ENGINE_RATE
+	}
+	return &Engine{
+		Nameserver:  nameserver,
+		Sockets:     sockets,
+		MaxInflight: DEFAULT_ENGINE_INFLIGHT,
+		Retries:     DEFAULT_ENGINE_RETRIES,
+		Timeout:     DEFAULT_ENGINE_TIMEOUT,
+		rate:        float64(rate),
+		window:      ENGINE_INITIAL_WINDOW,
+	}
+}
+
+// Run resolves every query read from in and streams the results back. The
+// returned channel is closed once in is closed and every query has a result.
+func (e *Engine) Run(in <-chan Query) (<-chan Result, error) {
+	raddr, err := net.ResolveUDPAddr("udp", nsAddress(e.Nameserver))
+	if err != nil {
+		return nil, fmt.Errorf("Nameserver Resolution Error: %v", err)
+	}
+	e.conns = make([]*net.UDPConn, e.Sockets)
+	e.pending = make([]map[uint16]*inflight, e.Sockets)
+	e.nextID = make([]uint16, e.Sockets)
+	for i := range e.conns {
+		conn, err := net.DialUDP("udp", nil, raddr)
+		if err != nil {
+			for _, c := range e.conns[:i] {
+				c.Close()
+			}
+			return nil, fmt.Errorf("Socket Error: %v", err)
+		}
+		conn.SetReadBuffer(4 * 1024 * 1024)
+		conn.SetWriteBuffer(4 * 1024 * 1024)
+		e.conns[i] = conn
+		e.pending[i] = make(map[uint16]*inflight)
+		e.nextID[i] = uint16(time.Now().UnixNano())
+	}
+	e.slots = make(chan struct{}, e.MaxInflight)
+	e.out = make(chan Result, 1000)
+	e.last = time.Now()
+
+	var readers sync.WaitGroup
+	for i := range e.conns {
+		readers.Add(1)
+		go e.receive(i, &readers)
+	}
+	stop := make(chan struct{})
+	go e.sweep(stop)
+
+	go func() {
+		e.dispatch(in)
+		close(stop)
+		for _, conn := range e.conns {
+			conn.Close()
+		}
+		readers.Wait()
+		close(e.out)
+	}()
+	return e.out, nil
+}
+
+// dispatch feeds retries and new queries to the sockets until every query
+// that was read from in has produced a result.
+func (e *Engine) dispatch(in <-chan Query) {
+	var outstanding sync.WaitGroup
+	var done chan struct{}
+	tick := time.NewTicker(ENGINE_SWEEP_INTERVAL / 5)
+	defer tick.Stop()
+
+	for {
+		q := e.nextRetry()
+		if q == nil {
+			if in == nil {
+				select {
+				case <-done:
+					return
+				case <-tick.C:
+				}
+				continue
+			}
+			select {
+			case query, ok := <-in:
+				if !ok {
+					in = nil
+					done = make(chan struct{})
+					go func() {
+						outstanding.Wait()
+						close(done)
+					}()
+					continue
+				}
+				outstanding.Add(1)
+				q = &inflight{query: query, done: outstanding.Done}
+			case <-tick.C:
+				continue
+			}
+		}
+		e.slots <- struct{}{}
+		e.hold()
+		e.pace()
+		e.send(q)
+	}
+}
+
+func (e *Engine) nextRetry() *inflight {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for i, q := range e.retries {
+		if now.After(q.notBefore) {
+			e.retries = append(e.retries[:i], e.retries[i+1:]...)
+			return q
+		}
+	}
+	return nil
+}
+
+// hold blocks while the queries in flight fill the congestion window.
+func (e *Engine) hold() {
+	waited := false
+	for {
+		e.mu.Lock()
+		full := float64(len(e.slots)) > e.window
+		e.mu.Unlock()
+		if !full {
+			break
+		}
+		waited = true
+		time.Sleep(time.Millisecond)
+	}
+	if waited {
+		atomic.AddInt64(&e.sweepLimited, 1)
+	}
+}
+
+// pace blocks until the token bucket allows another packet at the current rate.
+func (e *Engine) pace() {
+	for {
+		e.mu.Lock()
+		now := time.Now()
+		e.tokens += now.Sub(e.last).Seconds() * e.rate
+		if burst := e.rate / 20; e.tokens > burst {
+			e.tokens = burst
+		}
+		e.last = now
+		if e.tokens >= 1 {
+			e.tokens--
+			e.mu.Unlock()
+			return
+		}
+		wait := time.Duration((1 - e.tokens) / e.rate * float64(time.Second))
+		e.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+func (e *Engine) send(q *inflight) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(q.query.Name), q.query.Type)
+
+	e.mu.Lock()
+	sock := e.nextSock
+	e.nextSock = (e.nextSock + 1) % len(e.conns)
+	for {
+		e.nextID[sock]++
+		if _, used := e.pending[sock][e.nextID[sock]]; !used {
+			break
+		}
+	}
+	msg.Id = e.nextID[sock]
+	q.sock, q.id = sock, msg.Id
+	q.attempts++
+	q.sent = time.Now()
+	q.window = e.window
+	e.pending[sock][q.id] = q
+	e.mu.Unlock()
+
+	buf, err := msg.Pack()
+	if err == nil {
+		_, err = e.conns[sock].Write(buf)
+	}
+	if err != nil {
+		e.mu.Lock()
+		delete(e.pending[sock], q.id)
+		e.mu.Unlock()
+		<-e.slots
+		e.finish(q, nil, fmt.Errorf("Send Error: %v", err))
+		return
+	}
+	atomic.AddInt64(&e.sent, 1)
+	atomic.AddInt64(&e.sweepSent, 1)
+}
+
+func (e *Engine) receive(sock int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	buf := make([]byte, ENGINE_BUFFER_SIZE)
+	for {
+		n, err := e.conns[sock].Read(buf)
+		if err != nil {
+			return
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(buf[:n]); err != nil || len(msg.Question) == 0 {
+			continue
+		}
+		e.mu.Lock()
+		q, ok := e.pending[sock][msg.Id]
+		if !ok || q.query.Type != msg.Question[0].Qtype || !strings.EqualFold(dns.Fqdn(q.query.Name), msg.Question[0].Name) {
+			e.mu.Unlock()
+			continue
+		}
+		delete(e.pending[sock], msg.Id)
+		e.mu.Unlock()
+		<-e.slots
+		atomic.AddInt64(&e.received, 1)
+		atomic.AddInt64(&e.sweepAnswers, 1)
+		atomic.AddInt64(&e.sweepRTT, int64(time.Since(q.sent)))
+
+		switch {
+		case msg.Truncated:
+			go e.fallbackTCP(q)
+		case msg.Rcode == dns.RcodeServerFailure && q.attempts <= e.Retries:
+			e.retry(q)
+		default:
+			e.finish(q, msg, nil)
+		}
+	}
+}
+
+func (e *Engine) fallbackTCP(q *inflight) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(q.query.Name), q.query.Type)
+	client := &dns.Client{Net: "tcp", Timeout: e.Timeout}
+	in, _, err := client.Exchange(msg, nsAddress(e.Nameserver))
+	e.finish(q, in, err)
+}
+
+// sweep expires queries that were never answered and adjusts the send rate
+// and window. Losses of one congestion episode keep expiring for a whole
+// timeout, so the rate is cut once per timeout and the window is cut relative
+// to the window the lost queries were sent with. A rising RTT cuts the window
+// right away.
+func (e *Engine) sweep(stop chan struct{}) {
+	tick := time.NewTicker(ENGINE_SWEEP_INTERVAL)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		now := time.Now()
+		expired := make([]*inflight, 0)
+		lossWindow := 0.0
+		e.mu.Lock()
+		for sock := range e.pending {
+			for id, q := range e.pending[sock] {
+				if now.Sub(q.sent) > e.Timeout {
+					delete(e.pending[sock], id)
+					expired = append(expired, q)
+					if lossWindow == 0 || q.window < lossWindow {
+						lossWindow = q.window
+					}
+				}
+			}
+		}
+		sent := atomic.SwapInt64(&e.sweepSent, 0)
+		lost := int64(len(expired))
+		answers := atomic.SwapInt64(&e.sweepAnswers, 0)
+		rtt := time.Duration(atomic.SwapInt64(&e.sweepRTT, 0))
+		limited := atomic.SwapInt64(&e.sweepLimited, 0) > 0
+		congested := false
+		if answers > 0 {
+			rtt /= time.Duration(answers)
+			if e.minRTT == 0 || rtt < e.minRTT {
+				e.minRTT = rtt
+			}
+			congested = rtt > e.minRTT+ENGINE_QUEUE_DELAY
+		}
+		switch loss := sent > 0 && float64(lost)/float64(sent) > ENGINE_LOSS_THRESHOLD; {
+		case loss:
+			e.threshold = max(lossWindow*ENGINE_RATE_DECREASE, ENGINE_MIN_WINDOW)
+			e.window = min(e.window, e.threshold)
+			if now.Sub(e.lastCut) > e.Timeout {
+				e.rate = max(e.rate*ENGINE_RATE_DECREASE, ENGINE_MIN_RATE)
+				e.lastCut = now
+			}
+		case congested:
+			e.window = max(e.window*ENGINE_RATE_DECREASE, ENGINE_MIN_WINDOW)
+		case sent > 0:
+			e.rate = min(e.rate*ENGINE_RATE_INCREASE, ENGINE_MAX_RATE)
+			if !limited {
+				break
+			}
+			if e.threshold == 0 || e.window < e.threshold {
+				e.window *= ENGINE_WINDOW_INCREASE
+			} else {
+				e.window += ENGINE_MIN_WINDOW
+			}
+			e.window = min(e.window, float64(e.MaxInflight))
+		}
+		e.mu.Unlock()
+
+		for _, q := range expired {
+			<-e.slots
+			atomic.AddInt64(&e.timeouts, 1)
+			if q.attempts <= e.Retries {
+				e.retry(q)
+				continue
+			}
+			e.finish(q, nil, fmt.Errorf("Timeout after %d attempts", q.attempts))
+		}
+	}
+}
+
+func (e *Engine) retry(q *inflight) {
+	atomic.AddInt64(&e.resent, 1)
+	q.notBefore = time.Now().Add(ENGINE_BACKOFF << (q.attempts - 1))
+	e.mu.Lock()
+	e.retries = append(e.retries, q)
+	e.mu.Unlock()
+}
+
+func (e *Engine) finish(q *inflight, msg *dns.Msg, err error) {
+	e.out <- Result{
+		Query:    q.query,
+		Msg:      msg,
+		Err:      err,
+		Attempts: q.attempts,
+		RTT:      time.Since(q.sent),
+	}
+	q.done()
+}
+
+func (e *Engine) Rate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rate
+}
+
+func (e *Engine) Window() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return int(e.window)
+}
+
+func (e *Engine) Timeouts() int64 {
+	return atomic.LoadInt64(&e.timeouts)
+}
+
+func (e *Engine) Stats() string {
+	return fmt.Sprintf("Sent: %d | Received: %d | Timeouts: %d | Retries: %d | Rate: %.0f q/s | Window: %d",
+		atomic.LoadInt64(&e.sent), atomic.LoadInt64(&e.received),
+		atomic.LoadInt64(&e.timeouts), atomic.LoadInt64(&e.resent), e.Rate(), e.Window())
+}
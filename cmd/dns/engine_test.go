@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startServer runs an in-process UDP nameserver that answers every A and AAAA query.
+func startServer(tb testing.TB) string {
+	tb.Helper()
+	return serve(tb, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch q.Qtype {
+		case dns.TypeA:
+			m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(192, 0, 2, 1)})
+		case dns.TypeAAAA:
+			m.Answer = append(m.Answer, &dns.AAAA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 300}, AAAA: net.ParseIP("2001:db8::1")})
+		}
+		w.WriteMsg(m)
+	}))
+}
+
+// serve runs handler on an in-process UDP nameserver and returns its address.
+func serve(tb testing.TB, handler dns.Handler) string {
+	tb.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	// Bursts at the engine's rate overflow the default receive buffer
+	pc.(*net.UDPConn).SetReadBuffer(4 * 1024 * 1024)
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	tb.Cleanup(func() { server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// Throughput the engine has to reach against the in-process server, and the
+// share of queries allowed to time out on the way
+const (
+	minEngineQPS     = 20000
+	maxTimeoutsShare = 0.01
+)
+
+// resolve pushes n queries through a fresh engine and returns how many were answered.
+func resolve(tb testing.TB, nameserver string, n int) (int, time.Duration, *Engine) {
+	tb.Helper()
+	engine := NewEngine(nameserver, DEFAULT_ENGINE_SOCKETS, ENGINE_MAX_RATE)
+	queries := make(chan Query, 1000)
+	results, err := engine.Run(queries)
+	if err != nil {
+		tb.Fatalf("engine: %v", err)
+	}
+	start := time.Now()
+	go func() {
+		for i := 0; i < n; i++ {
+			queries <- Query{fmt.Sprintf("host%d.example.test.", i), dns.TypeA}
+		}
+		close(queries)
+	}()
+	answered := 0
+	for res := range results {
+		if res.Err == nil && res.Msg != nil && len(res.Msg.Answer) > 0 {
+			answered++
+		}
+	}
+	tb.Log(engine.Stats())
+	return answered, time.Since(start), engine
+}
+
+func TestEngineThroughput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("throughput test")
+	}
+	const n = 50000
+	answered, took, engine := resolve(t, startServer(t), n)
+	if answered != n {
+		t.Fatalf("answered %d of %d queries", answered, n)
+	}
+	qps := float64(n) / took.Seconds()
+	t.Logf("%d queries in %s: %.0f qps", n, took, qps)
+	if timeouts := engine.Timeouts(); float64(timeouts) > maxTimeoutsShare*n {
+		t.Errorf("%d of %d queries timed out, the send rate overruns the server", timeouts, n)
+	}
+	if !raceEnabled && qps < minEngineQPS {
+		t.Errorf("%.0f qps, want at least %d", qps, minEngineQPS)
+	}
+}
+
+func BenchmarkEngine(b *testing.B) {
+	nameserver := startServer(b)
+	b.ResetTimer()
+	answered, took, _ := resolve(b, nameserver, b.N)
+	b.StopTimer()
+	if answered != b.N {
+		b.Fatalf("answered %d of %d queries", answered, b.N)
+	}
+	b.ReportMetric(float64(b.N)/took.Seconds(), "qps")
+}
@@ -0,0 +1,327 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	PROVIDER_UNKNOWN = "Unknown"
+	PROVIDER_PRIVATE = "Private / Reserved"
+)
+
+// Organization name fragments of the large cloud, CDN and hosting networks
+var cloudProviders = []string{
+	"akamai", "alibaba", "amazon", "cloudflare", "digitalocean", "fastly",
+	"google", "hetzner", "incapsula", "linode", "microsoft", "oracle",
+	"ovh", "rackspace", "salesforce", "tencent", "vultr", "zscaler",
+}
+
+type Enrichment struct {
+	Address  string
+	ASN      uint
+	Org      string
+	Country  string
+	Netblock string
+	Names    []string
+}
+
+func (e *Enrichment) Provider() string {
+	if e.ASN == 0 && e.Org == "" {
+		return PROVIDER_UNKNOWN
+	}
+	return fmt.Sprintf("AS%d %s", e.ASN, e.Org)
+}
+
+func (e *Enrichment) Cloud() bool {
+	org := strings.ToLower(e.Org)
+	return slices.ContainsFunc(cloudProviders, func(p string) bool { return strings.Contains(org, p) })
+}
+
+type asnRange struct {
+	Start   netip.Addr
+	End     netip.Addr
+	ASN     uint
+	Country string
+	Org     string
+}
+
+// Enricher annotates addresses from local MMDB and ip2asn files without
+// sending a single packet.
+type Enricher struct {
+	readers []*maxminddb.Reader
+	ranges  []asnRange
+}
+
+type mmdbRecord struct {
+	ASN     uint   `maxminddb:"autonomous_system_number"`
+	Org     string `maxminddb:"autonomous_system_organization"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+func NewEnricher(mmdbFiles []string, ip2asn string) (*Enricher, error) {
+	e := &Enricher{
+		readers: make([]*maxminddb.Reader, 0, len(mmdbFiles)),
+		ranges:  make([]asnRange, 0),
+	}
+	for _, file := range mmdbFiles {
+		reader, err := maxminddb.Open(file)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("MMDB Open Error: %s: %v", file, err)
+		}
+		e.readers = append(e.readers, reader)
+	}
+	if ip2asn != "" {
+		if err := e.loadIP2ASN(ip2asn); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *Enricher) Close() {
+	for _, reader := range e.readers {
+		reader.Close()
+	}
+}
+
+// loadIP2ASN reads an iptoasn.com style TSV: range_start range_end AS_number country AS_description
+func (e *Enricher) loadIP2ASN(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("ip2asn Open Error: %v", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		start, err1 := netip.ParseAddr(fields[0])
+		end, err2 := netip.ParseAddr(fields[1])
+		asn, err3 := strconv.ParseUint(fields[2], 10, 32)
+		if err1 != nil || err2 != nil || err3 != nil || asn == 0 {
+			continue
+		}
+		e.ranges = append(e.ranges, asnRange{start, end, uint(asn), fields[3], fields[4]})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ip2asn Read Error: %v", err)
+	}
+	sort.Slice(e.ranges, func(i, j int) bool { return e.ranges[i].Start.Less(e.ranges[j].Start) })
+	return nil
+}
+
+func (e *Enricher) Lookup(address string) *Enrichment {
+	result := &Enrichment{Address: address}
+	ip := net.ParseIP(address)
+	addr, err := netip.ParseAddr(address)
+	if ip == nil || err != nil {
+		return result
+	}
+	addr = addr.Unmap()
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || reservedPrefix(addr) {
+		result.Org = PROVIDER_PRIVATE
+		return result
+	}
+
+	for _, reader := range e.readers {
+		var record mmdbRecord
+		network, ok, err := reader.LookupNetwork(ip, &record)
+		if err != nil || !ok {
+			continue
+		}
+		if result.Netblock == "" {
+			result.Netblock = network.String()
+		}
+		if result.ASN == 0 && record.ASN != 0 {
+			result.ASN, result.Org = record.ASN, record.Org
+		}
+		if result.Country == "" {
+			result.Country = record.Country.ISOCode
+			if result.Country == "" {
+				result.Country = record.RegisteredCountry.ISOCode
+			}
+		}
+	}
+
+	i := sort.Search(len(e.ranges), func(i int) bool { return addr.Less(e.ranges[i].Start) }) - 1
+	if i >= 0 && addr.BitLen() == e.ranges[i].Start.BitLen() && !e.ranges[i].End.Less(addr) {
+		r := e.ranges[i]
+		if result.ASN == 0 {
+			result.ASN, result.Org = r.ASN, r.Org
+		}
+		if result.Country == "" {
+			result.Country = r.Country
+		}
+		if result.Netblock == "" {
+			result.Netblock = rangeString(r.Start, r.End)
+		}
+	}
+	return result
+}
+
+// Enrich looks up every address and keeps the names it was resolved from.
+func (e *Enricher) Enrich(addresses map[string][]string) []*Enrichment {
+	results := make([]*Enrichment, 0, len(addresses))
+	for addr, names := range addresses {
+		result := e.Lookup(addr)
+		result.Names = names
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Address < results[j].Address })
+	return results
+}
+
+func PrintEnrichment(results []*Enrichment) {
+	groups := make(map[string][]*Enrichment)
+	for _, result := range results {
+		provider := result.Provider()
+		if result.Org == PROVIDER_PRIVATE {
+			provider = PROVIDER_PRIVATE
+		}
+		groups[provider] = append(groups[provider], result)
+	}
+	providers := make([]string, 0, len(groups))
+	for provider := range groups {
+		providers = append(providers, provider)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		if len(groups[providers[i]]) != len(groups[providers[j]]) {
+			return len(groups[providers[i]]) > len(groups[providers[j]])
+		}
+		return providers[i] < providers[j]
+	})
+
+	for _, provider := range providers {
+		members := groups[provider]
+		if members[0].Cloud() {
+			color.Yellow("  [ %s ] (cloud/CDN) hosts: %d", provider, len(members))
+		} else {
+			fmt.Printf("  [ %s ] hosts: %d\n", provider, len(members))
+		}
+		for i, result := range members {
+			line := fmt.Sprintf("%s\t%s\t%s\t%s", result.Address, orDash(result.Netblock), orDash(result.Country), strings.Join(result.Names, ", "))
+			if i == len(members)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+}
+
+// Addresses maps every A and AAAA address in the records to the names that point at it.
+func (r *Records) Addresses() map[string][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addresses := make(map[string][]string)
+	for _, rrs := range r.Data {
+		for _, rr := range rrs {
+			switch v := rr.(type) {
+			case *dns.A:
+				addNamed(addresses, v.A.String(), v.Hdr.Name)
+			case *dns.AAAA:
+				addNamed(addresses, v.AAAA.String(), v.Hdr.Name)
+			}
+		}
+	}
+	return addresses
+}
+
+func (a *AXFR) Addresses() map[string][]string {
+	addresses := make(map[string][]string)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, rec := range a.transfers {
+		MergeAddresses(addresses, rec.Addresses())
+	}
+	return addresses
+}
+
+func (inv *Inventory) Addresses() map[string][]string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	addresses := make(map[string][]string)
+	for name, host := range inv.Hosts {
+		for _, addr := range host.Addrs {
+			addNamed(addresses, addr, name)
+		}
+	}
+	return addresses
+}
+
+// MergeAddresses folds src into dst without duplicating names.
+func MergeAddresses(dst, src map[string][]string) {
+	for addr, names := range src {
+		for _, name := range names {
+			addNamed(dst, addr, name)
+		}
+	}
+}
+
+func addNamed(addresses map[string][]string, addr, name string) {
+	name = strings.ToLower(name)
+	if !slices.Contains(addresses[addr], name) {
+		addresses[addr] = append(addresses[addr], name)
+		sort.Strings(addresses[addr])
+	}
+}
+
+// reservedPrefix covers the shared and documentation ranges net.IP does not flag.
+func reservedPrefix(addr netip.Addr) bool {
+	for _, prefix := range []string{"100.64.0.0/10", "192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "198.18.0.0/15", "2001:db8::/32"} {
+		if netip.MustParsePrefix(prefix).Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func rangeString(start, end netip.Addr) string {
+	for bits := 0; bits <= start.BitLen(); bits++ {
+		prefix := netip.PrefixFrom(start, bits).Masked()
+		if prefix.Addr() != start {
+			continue
+		}
+		if last := lastAddr(prefix); last == end {
+			return prefix.String()
+		}
+	}
+	return start.String() + "-" + end.String()
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
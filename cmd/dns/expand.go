@@ -0,0 +1,226 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Maximum number of CNAME hops followed before a chain is considered a loop
+const MAX_CNAME_DEPTH = 16
+
+var expandTypes = [...]uint16{
+	dns.TypeMX,
+	dns.TypeNS,
+	dns.TypeSRV,
+	dns.TypeCNAME,
+}
+
+type Host struct {
+	Name   string
+	Chain  []string
+	Addrs  []string
+	Source string
+}
+
+type Inventory struct {
+	Hosts map[string]*Host
+	mu    sync.Mutex
+}
+
+func NewInventory() *Inventory {
+	return &Inventory{
+		Hosts: make(map[string]*Host),
+	}
+}
+
+// Expander follows every name referenced by a record set until no new names turn up.
+type Expander struct {
+	Domain     string
+	Nameserver string
+	Threads    int
+	Inventory  *Inventory
+	visited    *sync.Map
+	pending    sync.WaitGroup
+	queue      chan *Host
+}
+
+func NewExpander(domain, nameserver string, threads int) *Expander {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	return &Expander{
+		Domain:     dns.Fqdn(domain),
+		Nameserver: nameserver,
+		Threads:    threads,
+		Inventory:  NewInventory(),
+		visited:    &sync.Map{},
+		queue:      make(chan *Host, 100),
+	}
+}
+
+// Targets returns every hostname referenced by the MX, NS, SRV and CNAME records collected so far.
+func (r *Records) Targets() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0)
+	for _, recordType := range expandTypes {
+		for _, rr := range r.Data[recordType] {
+			if target := rrTarget(rr); target != "" && target != "." {
+				names = append(names, dns.Fqdn(strings.ToLower(target)))
+			}
+		}
+	}
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+func rrTarget(rr dns.RR) string {
+	switch v := rr.(type) {
+	case *dns.MX:
+		return v.Mx
+	case *dns.NS:
+		return v.Ns
+	case *dns.SRV:
+		return v.Target
+	case *dns.CNAME:
+		return v.Target
+	}
+	return ""
+}
+
+func (e *Expander) Expand(seeds []string) *Inventory {
+	for i := 0; i < e.Threads; i++ {
+		go e.worker()
+	}
+	e.Add(e.Domain, "seed")
+	for _, s := range seeds {
+		e.Add(s, "record")
+	}
+	e.pending.Wait()
+	close(e.queue)
+	return e.Inventory
+}
+
+// Add queues a name for resolution unless it was already seen.
+func (e *Expander) Add(name, source string) {
+	name = dns.Fqdn(strings.ToLower(name))
+	if _, seen := e.visited.LoadOrStore(name, true); seen {
+		return
+	}
+	e.pending.Add(1)
+	go func() {
+		e.queue <- &Host{Name: name, Source: source}
+	}()
+}
+
+func (e *Expander) worker() {
+	for host := range e.queue {
+		e.resolve(host)
+		e.pending.Done()
+	}
+}
+
+func (e *Expander) resolve(host *Host) {
+	name := host.Name
+	for depth := 0; depth < MAX_CNAME_DEPTH; depth++ {
+		rrs, err := lookup(name, dns.TypeCNAME, e.Nameserver)
+		if err != nil || len(rrs) == 0 {
+			break
+		}
+		target := ""
+		for _, rr := range rrs {
+			if cname, ok := rr.(*dns.CNAME); ok {
+				target = dns.Fqdn(strings.ToLower(cname.Target))
+			}
+		}
+		if target == "" || slices.Contains(host.Chain, target) {
+			break
+		}
+		host.Chain = append(host.Chain, target)
+		name = target
+	}
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		rrs, err := lookup(name, qtype, e.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			switch v := rr.(type) {
+			case *dns.A:
+				host.Addrs = append(host.Addrs, v.A.String())
+			case *dns.AAAA:
+				host.Addrs = append(host.Addrs, v.AAAA.String())
+			}
+		}
+	}
+	sort.Strings(host.Addrs)
+	host.Addrs = slices.Compact(host.Addrs)
+
+	e.Inventory.mu.Lock()
+	e.Inventory.Hosts[host.Name] = host
+	e.Inventory.mu.Unlock()
+
+	for _, target := range host.Chain {
+		e.Add(target, "cname:"+host.Name)
+	}
+	if !dns.IsSubDomain(e.Domain, host.Name) {
+		return
+	}
+	for _, recordType := range expandTypes {
+		rrs, err := lookup(host.Name, recordType, e.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			if target := rrTarget(rr); target != "" && target != "." {
+				e.Add(target, strings.ToLower(dns.TypeToString[recordType])+":"+host.Name)
+			}
+		}
+	}
+}
+
+func (inv *Inventory) Names() []string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	names := make([]string, 0, len(inv.Hosts))
+	for name := range inv.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Drop removes names that are already accounted for elsewhere.
+func (inv *Inventory) Drop(names []string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, name := range names {
+		delete(inv.Hosts, dns.Fqdn(strings.ToLower(name)))
+	}
+}
+
+func (inv *Inventory) Print() {
+	names := inv.Names()
+	for i, name := range names {
+		host := inv.Hosts[name]
+		addrs := strings.Join(host.Addrs, ", ")
+		if len(host.Addrs) == 0 {
+			addrs = "(unresolved)"
+		}
+		line := fmt.Sprintf("%s\t%s", name, addrs)
+		if len(host.Chain) > 0 {
+			line = fmt.Sprintf("%s\t[%s]", line, strings.Join(host.Chain, " -> "))
+		}
+		if i == len(names)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+}
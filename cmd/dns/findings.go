@@ -0,0 +1,88 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+const (
+	SEVERITY_HIGH   = "HIGH"
+	SEVERITY_MEDIUM = "MEDIUM"
+	SEVERITY_LOW    = "LOW"
+	SEVERITY_INFO   = "INFO"
+)
+
+var severityOrder = []string{
+	SEVERITY_HIGH,
+	SEVERITY_MEDIUM,
+	SEVERITY_LOW,
+	SEVERITY_INFO,
+}
+
+type Finding struct {
+	Severity string
+	Category string
+	Subject  string
+	Detail   string
+}
+
+// Findings collects report worthy issues from the different audits.
+type Findings struct {
+	List []Finding
+	mu   sync.Mutex
+}
+
+func NewFindings() *Findings {
+	return &Findings{
+		List: make([]Finding, 0),
+	}
+}
+
+func (f *Findings) Add(severity, category, subject, detail string) {
+	f.mu.Lock()
+	f.List = append(f.List, Finding{severity, category, subject, detail})
+	f.mu.Unlock()
+}
+
+func (f *Findings) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.List)
+}
+
+func (f *Findings) Sorted() []Finding {
+	f.mu.Lock()
+	list := slices.Clone(f.List)
+	f.mu.Unlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		return slices.Index(severityOrder, list[i].Severity) < slices.Index(severityOrder, list[j].Severity)
+	})
+	return list
+}
+
+func (f *Findings) Print() {
+	list := f.Sorted()
+	if len(list) == 0 {
+		fmt.Printf("  |_____No findings\n\n")
+		return
+	}
+	for i, finding := range list {
+		prefix := "  | \t"
+		if i == len(list)-1 {
+			prefix = "  |_____"
+		}
+		label := fmt.Sprintf("[%s]", finding.Severity)
+		switch finding.Severity {
+		case SEVERITY_HIGH:
+			label = color.RedString(label)
+		case SEVERITY_MEDIUM:
+			label = color.YellowString(label)
+		}
+		fmt.Printf("%s%s %s | %s: %s\n", prefix, label, finding.Category, finding.Subject, finding.Detail)
+	}
+	fmt.Println()
+}
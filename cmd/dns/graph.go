@@ -0,0 +1,277 @@
+package dns
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	NODE_DOMAIN     = "domain"
+	NODE_NAMESERVER = "nameserver"
+	NODE_MAIL       = "mailserver"
+	NODE_HOST       = "host"
+	NODE_ADDRESS    = "address"
+	NODE_NETBLOCK   = "netblock"
+
+	EDGE_AXFR     = "AXFR"
+	EDGE_NETBLOCK = "netblock"
+)
+
+// Higher ranked kinds win when one name shows up in several roles
+var nodeRank = map[string]int{
+	NODE_DOMAIN:     5,
+	NODE_NAMESERVER: 4,
+	NODE_MAIL:       3,
+	NODE_HOST:       2,
+	NODE_NETBLOCK:   1,
+	NODE_ADDRESS:    1,
+}
+
+var dotStyle = map[string]string{
+	NODE_DOMAIN:     `shape=box, style=filled, fillcolor="#9ecae1"`,
+	NODE_NAMESERVER: `shape=hexagon, style=filled, fillcolor="#fdd0a2"`,
+	NODE_MAIL:       `shape=octagon, style=filled, fillcolor="#c7e9c0"`,
+	NODE_HOST:       `shape=ellipse`,
+	NODE_ADDRESS:    `shape=plaintext`,
+	NODE_NETBLOCK:   `shape=folder, style=filled, fillcolor="#dadaeb"`,
+}
+
+type Node struct {
+	ID    string
+	Label string
+	Kind  string
+}
+
+type Edge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// Graph is the infrastructure of a domain as nodes (names, addresses,
+// netblocks) and the records or transfers linking them.
+type Graph struct {
+	Domain string
+	Nodes  map[string]*Node
+	Edges  map[Edge]bool
+}
+
+func NewGraph(domain string) *Graph {
+	g := &Graph{
+		Domain: dns.Fqdn(strings.ToLower(domain)),
+		Nodes:  make(map[string]*Node),
+		Edges:  make(map[Edge]bool),
+	}
+	g.node(g.Domain, NODE_DOMAIN)
+	return g
+}
+
+func (g *Graph) node(id, kind string) string {
+	if kind != NODE_ADDRESS && kind != NODE_NETBLOCK {
+		id = dns.Fqdn(strings.ToLower(id))
+	}
+	if n, ok := g.Nodes[id]; ok {
+		if nodeRank[kind] > nodeRank[n.Kind] {
+			n.Kind = kind
+		}
+		return id
+	}
+	g.Nodes[id] = &Node{ID: id, Label: id, Kind: kind}
+	return id
+}
+
+func (g *Graph) edge(from, to, label string) {
+	if from == to {
+		return
+	}
+	g.Edges[Edge{from, to, label}] = true
+}
+
+func (g *Graph) ownerKind(name string) string {
+	if strings.EqualFold(dns.Fqdn(name), g.Domain) {
+		return NODE_DOMAIN
+	}
+	return NODE_HOST
+}
+
+func (g *Graph) AddRecords(r *Records) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rrs := range r.Data {
+		for _, rr := range rrs {
+			owner := rr.Header().Name
+			switch v := rr.(type) {
+			case *dns.NS:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.Ns, NODE_NAMESERVER), "NS")
+			case *dns.MX:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.Mx, NODE_MAIL), "MX")
+			case *dns.CNAME:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.Target, NODE_HOST), "CNAME")
+			case *dns.SRV:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.Target, NODE_HOST), "SRV")
+			case *dns.A:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.A.String(), NODE_ADDRESS), "A")
+			case *dns.AAAA:
+				g.edge(g.node(owner, g.ownerKind(owner)), g.node(v.AAAA.String(), NODE_ADDRESS), "AAAA")
+			}
+		}
+	}
+}
+
+// AddTransfers links every zone to the nameservers that handed it out over AXFR.
+func (g *Graph) AddTransfers(a *AXFR) {
+	a.mu.Lock()
+	keys := make([]string, 0, len(a.transfers))
+	for key := range a.transfers {
+		keys = append(keys, key)
+	}
+	a.mu.Unlock()
+	for _, key := range keys {
+		zone, ns, ok := strings.Cut(key, "@")
+		if !ok {
+			continue
+		}
+		kind := NODE_NAMESERVER
+		if !strings.HasSuffix(ns, ".") {
+			kind = NODE_ADDRESS
+		}
+		g.edge(g.node(zone, g.ownerKind(zone)), g.node(ns, kind), EDGE_AXFR)
+		a.mu.Lock()
+		rec := a.transfers[key]
+		a.mu.Unlock()
+		g.AddRecords(rec)
+	}
+}
+
+func (g *Graph) AddInventory(inv *Inventory) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for name, host := range inv.Hosts {
+		if len(host.Chain) == 0 && len(host.Addrs) == 0 {
+			continue
+		}
+		from := g.node(name, g.ownerKind(name))
+		last := from
+		for _, target := range host.Chain {
+			next := g.node(target, NODE_HOST)
+			g.edge(last, next, "CNAME")
+			last = next
+		}
+		for _, addr := range host.Addrs {
+			label := "A"
+			if strings.Contains(addr, ":") {
+				label = "AAAA"
+			}
+			g.edge(last, g.node(addr, NODE_ADDRESS), label)
+		}
+	}
+}
+
+func (g *Graph) AddEnrichment(results []*Enrichment) {
+	for _, result := range results {
+		if result.Netblock == "" {
+			continue
+		}
+		block := g.node(result.Netblock, NODE_NETBLOCK)
+		if result.ASN != 0 {
+			g.Nodes[block].Label = fmt.Sprintf("%s\nAS%d %s", result.Netblock, result.ASN, result.Org)
+		}
+		g.edge(g.node(result.Address, NODE_ADDRESS), block, EDGE_NETBLOCK)
+	}
+}
+
+func (g *Graph) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+func (g *Graph) sortedEdges() []Edge {
+	edges := make([]Edge, 0, len(g.Edges))
+	for e := range g.Edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Label < edges[j].Label
+	})
+	return edges
+}
+
+func (g *Graph) WriteDOT(w io.Writer) error {
+	fmt.Fprintf(w, "digraph %q {\n\trankdir=LR;\n\tnode [fontname=\"Helvetica\"];\n", g.Domain)
+	for _, n := range g.sortedNodes() {
+		fmt.Fprintf(w, "\t%q [label=%q, %s];\n", n.ID, n.Label, dotStyle[n.Kind])
+	}
+	for _, e := range g.sortedEdges() {
+		style := ""
+		if e.Label == EDGE_AXFR {
+			style = `, color=red, style=bold`
+		}
+		fmt.Fprintf(w, "\t%q -> %q [label=%q%s];\n", e.From, e.To, e.Label, style)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="label" for="node" attr.name="label" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="kind" for="node" attr.name="kind" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="type" for="edge" attr.name="type" attr.type="string"/>`)
+	fmt.Fprintf(w, "  <graph id=\"%s\" edgedefault=\"directed\">\n", escape(g.Domain))
+	for _, n := range g.sortedNodes() {
+		fmt.Fprintf(w, "    <node id=\"%s\">\n", escape(n.ID))
+		fmt.Fprintf(w, "      <data key=\"label\">%s</data>\n", escape(n.Label))
+		fmt.Fprintf(w, "      <data key=\"kind\">%s</data>\n", n.Kind)
+		fmt.Fprintln(w, "    </node>")
+	}
+	for i, e := range g.sortedEdges() {
+		fmt.Fprintf(w, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, escape(e.From), escape(e.To))
+		fmt.Fprintf(w, "      <data key=\"type\">%s</data>\n", escape(e.Label))
+		fmt.Fprintln(w, "    </edge>")
+	}
+	fmt.Fprintln(w, "  </graph>")
+	_, err := fmt.Fprintln(w, "</graphml>")
+	return err
+}
+
+// Export writes the graph to file, as GraphML for .graphml/.xml and DOT otherwise.
+func (g *Graph) Export(file string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("Graph Export Error: %v", err)
+	}
+	defer out.Close()
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".graphml", ".xml":
+		err = g.WriteGraphML(out)
+	default:
+		err = g.WriteDOT(out)
+	}
+	if err != nil {
+		return fmt.Errorf("Graph Export Error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package dns
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+// Local parts that belong to a function rather than a person
+var roleAccounts = []string{
+	"abuse", "admin", "administrator", "billing", "contact", "dmarc", "dns", "domains", "forensics", "help",
+	"helpdesk", "hostmaster", "hr", "info", "it", "mail", "marketing", "noc", "noreply", "no-reply", "office",
+	"postmaster", "privacy", "reports", "root", "sales", "security", "support", "sysadmin", "tech", "webmaster",
+}
+
+// Address formats recognised from personal local parts, checked in order
+var addressFormats = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"{first}.{last}", regexp.MustCompile(`^[a-z]{2,}\.[a-z]{2,}$`)},
+	{"{first}_{last}", regexp.MustCompile(`^[a-z]{2,}_[a-z]{2,}$`)},
+	{"{first}-{last}", regexp.MustCompile(`^[a-z]{2,}-[a-z]{2,}$`)},
+	{"{f}.{last}", regexp.MustCompile(`^[a-z]\.[a-z]{2,}$`)},
+	{"{first}.{l}", regexp.MustCompile(`^[a-z]{2,}\.[a-z]$`)},
+	{"{first}{last} / {f}{last}", regexp.MustCompile(`^[a-z]{3,}$`)},
+}
+
+type Mailbox struct {
+	Address string
+	Person  string
+	Role    bool
+	Sources []string
+}
+
+// MailHarvester pulls mailboxes out of SOA, RP, TXT and CAA records and
+// guesses the address format the organization uses.
+type MailHarvester struct {
+	Domain    string
+	Mailboxes map[string]*Mailbox
+}
+
+func NewMailHarvester(domain string) *MailHarvester {
+	return &MailHarvester{
+		Domain:    dns.Fqdn(strings.ToLower(domain)),
+		Mailboxes: make(map[string]*Mailbox),
+	}
+}
+
+func (m *MailHarvester) AddRecords(r *Records) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, list := range r.Data {
+		for _, rr := range list {
+			m.check(rr)
+		}
+	}
+}
+
+func (m *MailHarvester) check(rr dns.RR) {
+	owner := strings.ToLower(rr.Header().Name)
+	switch v := rr.(type) {
+	case *dns.SOA:
+		m.add(mboxAddress(v.Mbox), owner+" SOA RNAME")
+	case *dns.RP:
+		m.add(mboxAddress(v.Mbox), owner+" RP")
+	case *dns.TXT:
+		text := strings.Join(v.Txt, "")
+		source := owner + " TXT"
+		switch lower := strings.ToLower(text); {
+		case strings.HasPrefix(lower, "v=dmarc1"):
+			source = owner + " DMARC"
+		case strings.HasPrefix(lower, "v=tlsrptv1"):
+			source = owner + " TLS-RPT"
+		}
+		for _, address := range emailPattern.FindAllString(text, -1) {
+			m.add(address, source)
+		}
+	case *dns.CAA:
+		for _, address := range emailPattern.FindAllString(v.Value, -1) {
+			m.add(address, owner+" CAA "+v.Tag)
+		}
+	}
+}
+
+func (m *MailHarvester) add(address, source string) {
+	address = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(address)), "mailto:")
+	local, domain, ok := strings.Cut(address, "@")
+	if !ok || local == "" || !strings.Contains(domain, ".") {
+		return
+	}
+	mailbox, ok := m.Mailboxes[address]
+	if !ok {
+		mailbox = &Mailbox{Address: address, Role: slices.Contains(roleAccounts, local), Sources: make([]string, 0)}
+		if !mailbox.Role {
+			mailbox.Person = personName(local)
+		}
+		m.Mailboxes[address] = mailbox
+	}
+	if !slices.Contains(mailbox.Sources, source) {
+		mailbox.Sources = append(mailbox.Sources, source)
+	}
+}
+
+// personName turns first.last style local parts into a display name.
+func personName(local string) string {
+	i := strings.IndexAny(local, "._-")
+	if i < 0 {
+		return ""
+	}
+	parts := []string{local[:i], local[i+1:]}
+	if strings.ContainsAny(parts[1], "._-") {
+		return ""
+	}
+	for i, part := range parts {
+		if part == "" || strings.ContainsAny(part, "0123456789") {
+			return ""
+		}
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, " ")
+}
+
+// Internal reports whether an address belongs to the domain or one of its subdomains.
+func (m *MailHarvester) Internal(address string) bool {
+	_, domain, _ := strings.Cut(address, "@")
+	return dns.IsSubDomain(m.Domain, dns.Fqdn(domain))
+}
+
+func (m *MailHarvester) Sorted() []*Mailbox {
+	mailboxes := make([]*Mailbox, 0, len(m.Mailboxes))
+	for _, mailbox := range m.Mailboxes {
+		sort.Strings(mailbox.Sources)
+		mailboxes = append(mailboxes, mailbox)
+	}
+	sort.Slice(mailboxes, func(i, j int) bool { return mailboxes[i].Address < mailboxes[j].Address })
+	return mailboxes
+}
+
+// Format returns the most common address format among personal mailboxes of
+// the domain together with how many of them follow it.
+func (m *MailHarvester) Format() (string, int, int) {
+	counts := make(map[string]int)
+	personal := 0
+	for _, mailbox := range m.Sorted() {
+		if mailbox.Role || !m.Internal(mailbox.Address) {
+			continue
+		}
+		personal++
+		local, _, _ := strings.Cut(mailbox.Address, "@")
+		for _, format := range addressFormats {
+			if format.Pattern.MatchString(local) {
+				counts[format.Name]++
+				break
+			}
+		}
+	}
+	best, count := "", 0
+	for _, format := range addressFormats {
+		if counts[format.Name] > count {
+			best, count = format.Name, counts[format.Name]
+		}
+	}
+	return best, count, personal
+}
+
+// Save writes the addresses of the domain one per line, ready for smtp -U.
+func (m *MailHarvester) Save(file string) (int, error) {
+	addresses := make([]string, 0)
+	for _, mailbox := range m.Sorted() {
+		if m.Internal(mailbox.Address) {
+			addresses = append(addresses, mailbox.Address)
+		}
+	}
+	content := strings.Join(addresses, "\n")
+	if len(addresses) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return 0, fmt.Errorf("Email File Error: %v", err)
+	}
+	return len(addresses), nil
+}
+
+func (m *MailHarvester) Print() {
+	color.Blue("[ Email Addresses ]")
+	mailboxes := m.Sorted()
+	if len(mailboxes) == 0 {
+		fmt.Printf("  |_____No addresses\n\n")
+		return
+	}
+	for _, mailbox := range mailboxes {
+		labels := make([]string, 0)
+		if mailbox.Person != "" {
+			labels = append(labels, mailbox.Person)
+		}
+		if mailbox.Role {
+			labels = append(labels, "role")
+		}
+		if !m.Internal(mailbox.Address) {
+			labels = append(labels, "external")
+		}
+		fmt.Printf("  | \t%s\t%s\t%s\n", mailbox.Address, orDash(strings.Join(labels, ", ")), strings.Join(mailbox.Sources, ", "))
+	}
+	format, count, personal := m.Format()
+	if format == "" {
+		fmt.Printf("  |_____Format: unknown (%d personal addresses)\n\n", personal)
+		return
+	}
+	fmt.Printf("  |_____Format: %s@%s (%d of %d personal addresses)\n\n", format, strings.TrimSuffix(m.Domain, "."), count, personal)
+}
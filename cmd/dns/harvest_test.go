@@ -0,0 +1,100 @@
+package dns
+
+import "testing"
+
+func TestPersonName(t *testing.T) {
+	names := map[string]string{
+		"john.doe":   "John Doe",
+		"jane_smith": "Jane Smith",
+		"mary-ann":   "Mary Ann",
+		"jdoe":       "",
+		"john.doe2":  "",
+		"a.b.c":      "",
+		"john..doe":  "",
+		".doe":       "",
+	}
+	for local, want := range names {
+		if got := personName(local); got != want {
+			t.Errorf("personName(%q) = %q, want %q", local, got, want)
+		}
+	}
+}
+
+func TestMailHarvesterFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		format    string
+		count     int
+		personal  int
+	}{
+		{
+			name:      "first.last wins",
+			addresses: []string{"john.doe@example.com", "jane.smith@example.com", "bob_jones@example.com"},
+			format:    "{first}.{last}",
+			count:     2,
+			personal:  3,
+		},
+		{
+			name:      "initial and last",
+			addresses: []string{"j.doe@example.com", "s.jones@example.com", "admin@example.com"},
+			format:    "{f}.{last}",
+			count:     2,
+			personal:  2,
+		},
+		{
+			name:      "role and external addresses do not count",
+			addresses: []string{"hostmaster@example.com", "dmarc@example.com", "john.doe@vendor.net", "jdoe@example.com"},
+			format:    "{first}{last} / {f}{last}",
+			count:     1,
+			personal:  1,
+		},
+		{
+			name:      "subdomain addresses belong to the domain",
+			addresses: []string{"ann_lee@mail.example.com"},
+			format:    "{first}_{last}",
+			count:     1,
+			personal:  1,
+		},
+		{
+			name:      "nothing personal",
+			addresses: []string{"postmaster@example.com", "x1@example.com"},
+			format:    "",
+			count:     0,
+			personal:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMailHarvester("example.com")
+			for _, address := range tt.addresses {
+				m.add(address, "test")
+			}
+			format, count, personal := m.Format()
+			if format != tt.format || count != tt.count || personal != tt.personal {
+				t.Errorf("Format() = %q %d/%d, want %q %d/%d", format, count, personal, tt.format, tt.count, tt.personal)
+			}
+		})
+	}
+}
+
+func TestMailHarvesterAdd(t *testing.T) {
+	m := NewMailHarvester("example.com")
+	m.add(" MAILTO:John.Doe@Example.com", "example.com. TXT")
+	m.add("john.doe@example.com", "example.com. RP")
+	m.add("john.doe@example.com", "example.com. RP")
+	m.add("not-an-address", "example.com. TXT")
+	m.add("@example.com", "example.com. TXT")
+	m.add("user@localhost", "example.com. TXT")
+
+	if len(m.Mailboxes) != 1 {
+		t.Fatalf("got %d mailboxes, want 1: %v", len(m.Mailboxes), m.Mailboxes)
+	}
+	mailbox := m.Mailboxes["john.doe@example.com"]
+	if mailbox == nil {
+		t.Fatal("john.doe@example.com was not normalised")
+	}
+	if mailbox.Person != "John Doe" || mailbox.Role || len(mailbox.Sources) != 2 {
+		t.Errorf("mailbox = %+v", mailbox)
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"net"
 	"slices"
+	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 
 	"github.com/fatih/color"
 	"github.com/miekg/dns"
@@ -101,6 +101,16 @@ var domainTypes = [...]uint16{
 type DNSTask struct {
 	Domain     string
 	Nameserver string
+	Host       string // nameserver name an address was resolved from
+	Depth      int
+}
+
+func (t DNSTask) Key() string {
+	ns := strings.ToLower(t.Nameserver)
+	if _, _, err := net.SplitHostPort(ns); err != nil && net.ParseIP(ns) == nil {
+		ns = dns.Fqdn(ns)
+	}
+	return dns.Fqdn(strings.ToLower(t.Domain)) + "@" + ns
 }
 
 type Records struct {
@@ -137,6 +147,9 @@ func (r *Records) CheckAllRecords(domain, nameserver string, recordsToCheck []ui
 
 	go func() {
 		for _, t := range recordsToCheck {
+			if t == dns.TypeAXFR {
+				continue
+			}
 			tasks <- t
 		}
 		close(tasks)
@@ -147,25 +160,34 @@ func (r *Records) CheckAllRecords(domain, nameserver string, recordsToCheck []ui
 	}
 
 	wg.Wait()
+}
 
-	color.Blue("[ Record Check Results ]")
-	r.Print()
-	if slices.Contains(recordsToCheck, dns.TypeAXFR) {
-		axfr := newAXFR()
-		for _, ns := range r.Data[dns.TypeNS] {
-			last := strings.Split(ns.String(), "\t")
-			nsEntry := last[len(last)-1]
-			axfr.AddTask(DNSTask{domain, nsEntry}, true)
-			// select {
-			// case axfr.tasks <- DNSTask{domain, nsEntry}:
-			// 	atomic.AddInt32(&counter, 1)
-			// default:
-			// 	panic("channel closed")
-			// }
+// NameServers returns the hosts named by the collected NS records.
+func (r *Records) NameServers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	servers := make([]string, 0)
+	for _, rr := range r.Data[dns.TypeNS] {
+		if ns, ok := rr.(*dns.NS); ok {
+			servers = append(servers, strings.ToLower(ns.Ns))
+		}
+	}
+	sort.Strings(servers)
+	return slices.Compact(servers)
+}
 
+// Names returns every owner name present in the collected records.
+func (r *Records) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0)
+	for _, rrs := range r.Data {
+		for _, rr := range rrs {
+			names = append(names, strings.ToLower(rr.Header().Name))
 		}
-		axfr.ZoneTransfer(domain, nameserver)
 	}
+	sort.Strings(names)
+	return slices.Compact(names)
 }
 
 func (r *Records) checkRecords(domain, nameserver string, tasks chan uint16, wg *sync.WaitGroup) {
@@ -174,7 +196,7 @@ func (r *Records) checkRecords(domain, nameserver string, tasks chan uint16, wg
 		msg := new(dns.Msg)
 		msg.SetQuestion(dns.Fqdn(domain), recordType)
 		client := new(dns.Client)
-		in, _, err := client.Exchange(msg, net.JoinHostPort(nameserver, "53"))
+		in, _, err := client.Exchange(msg, nsAddress(nameserver))
 		if err != nil {
 			fmt.Printf("[ERROR] DNS Lookup Failure: %v\n", err)
 			return
@@ -190,155 +212,290 @@ func (r *Records) checkRecords(domain, nameserver string, tasks chan uint16, wg
 	}
 }
 
+// lookup sends a single query for name to the nameserver and returns the answer section.
+func lookup(name string, qtype uint16, nameserver string) ([]dns.RR, error) {
+	in, err := exchange(name, qtype, nameserver)
+	if err != nil {
+		return nil, err
+	}
+	return in.Answer, nil
+}
+
+// exchange sends a single query for name to the nameserver and returns the whole response.
+func exchange(name string, qtype uint16, nameserver string) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	return exchangeMsg(msg, nameserver)
+}
+
+// exchangeDNSSEC asks for DNSSEC records alongside the answer. Checking is
+// disabled so a validating resolver hands back bogus data instead of SERVFAIL.
+func exchangeDNSSEC(name string, qtype uint16, nameserver string) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.SetEdns0(4096, true)
+	msg.CheckingDisabled = true
+	return exchangeMsg(msg, nameserver)
+}
+
+// exchangeMsg sends msg over UDP and retries over TCP when the answer was truncated.
+func exchangeMsg(msg *dns.Msg, nameserver string) (*dns.Msg, error) {
+	client := new(dns.Client)
+	in, _, err := client.Exchange(msg, nsAddress(nameserver))
+	if err == nil && in.Truncated {
+		client.Net = "tcp"
+		in, _, err = client.Exchange(msg, nsAddress(nameserver))
+	}
+	return in, err
+}
+
+// nsAddress appends the default DNS port to a nameserver unless one is already present.
+func nsAddress(nameserver string) string {
+	nameserver = strings.TrimSuffix(nameserver, ".")
+	if _, _, err := net.SplitHostPort(nameserver); err == nil {
+		return nameserver
+	}
+	return net.JoinHostPort(nameserver, "53")
+}
+
 type Transfers map[string]*Records
 
+// Attempt is the outcome of a single zone transfer against one server address.
+type Attempt struct {
+	Task    DNSTask
+	Records int
+	Err     error
+}
+
+func (at *Attempt) Status() string {
+	if at.Err == nil {
+		return fmt.Sprintf("allowed (%d records)", at.Records)
+	}
+	var rcode int
+	if _, err := fmt.Sscanf(at.Err.Error(), "dns: bad xfr rcode: %d", &rcode); err == nil {
+		return dns.RcodeToString[rcode]
+	}
+	return at.Err.Error()
+}
+
+// AXFR walks zone transfers recursively: every delegation and owner name found
+// in a successful transfer is queued against the nameservers that might serve it.
 type AXFR struct {
-	transfers map[string]*Records
-	tasks     chan DNSTask
-	results   chan DNSTask
-	visited   *sync.Map
-	failed    []string
+	Workers   int
+	MaxDepth  int
+	Resolver  string
+	transfers Transfers
+	attempts  map[string]*Attempt
+	frontier  *frontier[DNSTask]
 	mu        sync.Mutex
-	Counter   *int32
+}
+
+func NewAXFR(workers, maxDepth int) *AXFR {
+	if workers <= 0 {
+		workers = DEFAULT_AXFR_WORKERS
+	}
+	return &AXFR{
+		Workers:   workers,
+		MaxDepth:  maxDepth,
+		transfers: make(Transfers),
+		attempts:  make(map[string]*Attempt),
+		frontier:  newFrontier[DNSTask](),
+	}
+}
+
+// Names returns every owner name seen across all successful transfers.
+func (a *AXFR) Names() []string {
+	names := make([]string, 0)
+	a.mu.Lock()
+	for _, rec := range a.transfers {
+		names = append(names, rec.Names()...)
+	}
+	a.mu.Unlock()
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// Allowed returns the zone@nameserver keys of every successful transfer.
+func (a *AXFR) Allowed() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make([]string, 0, len(a.transfers))
+	for key := range a.transfers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (a *AXFR) printTransfers() {
-	for d, rec := range a.transfers {
-		rec.mu.Lock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make([]string, 0, len(a.transfers))
+	for d := range a.transfers {
+		keys = append(keys, d)
+	}
+	sort.Strings(keys)
+	for _, d := range keys {
+		rec := a.transfers[d]
 		if len(rec.Data) == 0 {
 			continue
 		}
 		color.Red("[------ %s ------]", d)
 		rec.Print()
-		rec.mu.Unlock()
 	}
 
+	keys = keys[:0]
+	for key := range a.attempts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		attempt := a.attempts[key]
+		line := fmt.Sprintf("%s\t%s", key, attempt.Status())
+		if attempt.Task.Host != "" {
+			line = fmt.Sprintf("%s (%s)\t%s", key, attempt.Task.Host, attempt.Status())
+		}
+		if attempt.Err == nil {
+			line = color.RedString(line)
+		}
+		if i == len(keys)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+	fmt.Printf("  Attempted: %d | Succeeded: %d | Failed: %d\n", len(a.attempts), len(a.transfers), len(a.attempts)-len(a.transfers))
 }
 
-func newAXFR() AXFR {
-	return AXFR{make(Transfers), make(chan DNSTask, 200), make(chan DNSTask, 200), &sync.Map{}, make([]string, 0), sync.Mutex{}, new(int32)}
-}
-
-func (a *AXFR) ZoneTransfer(domain, ns string) {
+// ZoneTransfer tries the zone against the resolver, the SOA MNAME and every
+// nameserver, by name and separately at each of its A and AAAA addresses.
+func (a *AXFR) ZoneTransfer(domain, ns string, nameservers []string) {
 	var wg sync.WaitGroup
-	const WORKERS = 5
 
-	// atomic.AddInt32(taskCounter, 1)
-	a.AddTask(DNSTask{domain, ns}, true)
-	// a.tasks <- DNSTask{domain, ns}
+	a.Resolver = ns
+	a.AddTask(DNSTask{Domain: domain, Nameserver: ns})
+	if primary := a.primary(domain); primary != "" {
+		nameservers = append(nameservers, primary)
+	}
+	for _, server := range nameservers {
+		a.addServer(domain, server, 0)
+	}
 
-	for i := 0; i < WORKERS; i++ {
+	for i := 0; i < a.Workers; i++ {
 		wg.Add(1)
 		go a.recurseTransfer(&wg)
 	}
-
-	// Add new tasks
-	go func() {
-		for newTask := range a.results {
-			a.tasks <- newTask
-
-		}
-	}()
-
-	var closeOnce sync.Once
-	go func() {
-		for {
-			if atomic.LoadInt32(a.Counter) == 0 {
-				closeOnce.Do(func() {
-					close(a.tasks)
-					close(a.results)
-
-				})
-				break
-			}
-
-		}
-	}()
 	wg.Wait()
-	color.Blue("[ Zone Transfer Results ]")
-	a.printTransfers()
-
 }
 
 func (a *AXFR) recurseTransfer(wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for task := range a.tasks {
-		dom := dns.Fqdn(task.Domain)
-		ns := dns.Fqdn(task.Nameserver)
-		// fmt.Printf("Starting %s @ %s\n ", dom, ns)
-		DN := dom + "@" + ns
-		a.mu.Lock()
-		if _, visit := a.visited.Load(DN); visit {
-			atomic.AddInt32(a.Counter, -1)
-			continue
+	for {
+		task, ok := a.frontier.Pop()
+		if !ok {
+			return
 		}
-		a.mu.Unlock()
-		a.visited.Store(DN, true)
-		a.transfers[DN] = NewRecords()
-		t := new(dns.Transfer)
-		msg := new(dns.Msg)
-		msg.SetAxfr(dom)
+		recs, err := a.transfer(task)
+		if err == nil {
+			a.expand(task, recs)
+		}
+		a.frontier.Done()
+	}
+}
 
-		stream, err := t.In(msg, net.JoinHostPort(ns, "53"))
-		if err != nil {
-			a.mu.Lock()
-			a.failed = append(a.failed, fmt.Sprintf("[AXFR Fail] - %s @ %s | %v", dom, ns, err))
-			a.mu.Unlock()
-			atomic.AddInt32(a.Counter, -1)
+func (a *AXFR) transfer(task DNSTask) (*Records, error) {
+	dom := dns.Fqdn(task.Domain)
+	ns := dns.Fqdn(task.Nameserver)
+	t := new(dns.Transfer)
+	msg := new(dns.Msg)
+	msg.SetAxfr(dom)
+
+	stream, err := t.In(msg, nsAddress(ns))
+	if err != nil {
+		a.record(task, 0, err)
+		return nil, err
+	}
+	recs := NewRecords()
+	count := 0
+	for r := range stream {
+		if r.Error != nil {
+			err = r.Error
 			continue
 		}
-		for r := range stream {
-			if r.Error != nil {
-				a.mu.Lock()
-				a.failed = append(a.failed, fmt.Sprintf("[AXFR Error] - %s @ %s | %v", dom, ns, err))
-				a.mu.Unlock()
-				continue
-			}
-			for _, answer := range r.RR {
-				a.transfers[DN].mu.Lock()
-				a.transfers[DN].Data[answer.Header().Rrtype] = append(a.transfers[DN].Data[answer.Header().Rrtype], answer)
-				a.transfers[DN].mu.Unlock()
-			}
+		for _, answer := range r.RR {
+			recs.Data[answer.Header().Rrtype] = append(recs.Data[answer.Header().Rrtype], answer)
+			count++
+		}
+	}
+	if len(recs.Data) == 0 {
+		if err == nil {
+			err = fmt.Errorf("Empty Transfer")
 		}
+		a.record(task, 0, err)
+		return nil, err
+	}
+	a.record(task, count, nil)
+	a.mu.Lock()
+	a.transfers[task.Key()] = recs
+	a.mu.Unlock()
+	return recs, nil
+}
 
-		nsRecs, hasNS := a.transfers[DN].Data[dns.TypeNS]
-		for _, types := range domainTypes {
-			a.transfers[DN].mu.Lock()
-			for _, r := range a.transfers[DN].Data[types] {
-				if hasNS {
-					for _, names := range nsRecs {
-						a.AddTask(DNSTask{dom, names.Header().Name}, false)
-						// atomic.AddInt32(counter, 1)
-						// a.results <- DNSTask{dom, names.Header().Name}
-					}
-				}
-				a.AddTask(DNSTask{r.Header().Name, ns}, false)
-				// atomic.AddInt32(counter, 1)
-				// a.results <- DNSTask{r.Header().Name, ns}
-			}
-			a.transfers[DN].mu.Unlock()
+// expand queues every delegation and owner name of a finished transfer one level deeper.
+func (a *AXFR) expand(task DNSTask, recs *Records) {
+	depth := task.Depth + 1
+	if a.MaxDepth > 0 && depth > a.MaxDepth {
+		return
+	}
+	for _, rr := range recs.Data[dns.TypeNS] {
+		if ns, ok := rr.(*dns.NS); ok {
+			a.addServer(ns.Header().Name, ns.Ns, depth)
+		}
+	}
+	for _, types := range domainTypes {
+		for _, rr := range recs.Data[types] {
+			a.AddTask(DNSTask{Domain: rr.Header().Name, Nameserver: task.Nameserver, Depth: depth})
 		}
-		atomic.AddInt32(a.Counter, -1)
 	}
+}
 
+func (a *AXFR) record(task DNSTask, records int, err error) {
+	a.mu.Lock()
+	a.attempts[task.Key()] = &Attempt{Task: task, Records: records, Err: err}
+	a.mu.Unlock()
 }
 
-func (a *AXFR) AddTask(task DNSTask, taskchan bool) {
-	if taskchan {
-		select {
-		case a.tasks <- task:
-			atomic.AddInt32(a.Counter, 1)
-		default:
-			return
-		}
-	} else {
-		select {
-		case a.results <- task:
-			atomic.AddInt32(a.Counter, 1)
-		default:
-			return
+// addServer queues the zone against a nameserver by name and at each of its
+// addresses, since anycast and dual-stack instances often differ in ACLs.
+func (a *AXFR) addServer(domain, server string, depth int) {
+	a.AddTask(DNSTask{Domain: domain, Nameserver: server, Depth: depth})
+	if _, _, err := net.SplitHostPort(server); err == nil || net.ParseIP(server) != nil || a.Resolver == "" {
+		return
+	}
+	for _, addr := range resolveAddrs(server, a.Resolver) {
+		a.AddTask(DNSTask{Domain: domain, Nameserver: addr, Host: server, Depth: depth})
+	}
+}
+
+// primary returns the SOA MNAME, the hidden primary is often left out of the NS set.
+func (a *AXFR) primary(domain string) string {
+	rrs, err := lookup(dns.Fqdn(domain), dns.TypeSOA, a.Resolver)
+	if err != nil {
+		return ""
+	}
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Ns
 		}
 	}
+	return ""
+}
 
+// AddTask queues a transfer unless the same zone was already tried against the same nameserver.
+func (a *AXFR) AddTask(task DNSTask) bool {
+	if task.Domain == "" || task.Nameserver == "" {
+		return false
+	}
+	return a.frontier.Push(task.Key(), task)
 }
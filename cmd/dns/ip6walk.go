@@ -0,0 +1,297 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	IP6_ARPA      = "ip6.arpa."
+	IP6_NIBBLES   = 32
+	IP6_CHILDREN  = "0123456789abcdef"
+	IP6_BITS_STEP = 4
+	IP6_RETRIES   = 3
+	IP6_BACKOFF   = 250 * time.Millisecond
+)
+
+const IP6_START_STRING = `
+[IP6.ARPA WALK]
+ Prefix: %s
+ Zone: %s
+ Nameserver: %s
+ Time Start: %s
+`
+
+var IP6Cmd = &cobra.Command{
+	Use:   "ip6",
+	Short: "IPv6 reverse zone walking",
+	Long: `
+[IP6.ARPA WALK]
+	[-- REQUIRED --]
+	-r <IPv6 prefix, nibble aligned: 2001:db8::/48>
+	-n <Nameserver authoritative for the reverse zone>
+
+	[-- OPTIONAL --]
+	-T <Thread Count>
+
+	[-- EXAMPLES --]
+	genum dns ip6 -r 2001:db8:1234::/48 -n ns1.example.com
+`,
+	PreRunE: validateIP6,
+	RunE:    executeIP6,
+}
+
+type IP6_Options struct {
+	utils.Options
+	Prefix     string
+	Nameserver string
+	Threads    int
+}
+
+func init() {
+	IP6Cmd.Flags().StringP("prefix", "r", "", "IPv6 prefix to walk: 2001:db8::/32")
+	IP6Cmd.Flags().StringP("nameserver", "n", "", "nameserver authoritative for the ip6.arpa zone")
+	IP6Cmd.Flags().IntP("threads", "T", DEFAULT_THREAD_COUNT, "Thread Count: Default: 10")
+	DNSCmd.AddCommand(IP6Cmd)
+}
+
+func validateIP6(cmd *cobra.Command, args []string) error {
+	var options = new(IP6_Options)
+	err := options.AddRequired(cmd,
+		"prefix", &options.Prefix,
+		"nameserver", &options.Nameserver,
+	)
+	if err != nil {
+		return err
+	}
+	err = options.Add(cmd,
+		"threads", &options.Threads,
+	)
+	if err != nil {
+		return err
+	}
+	cmd.SetContext(context.WithValue(cmd.Context(), Key{}, options))
+	return nil
+}
+
+func executeIP6(cmd *cobra.Command, args []string) error {
+	validatedArgs := cmd.Context().Value(Key{})
+	if validatedArgs == nil {
+		return fmt.Errorf("[Command Line Options Error]")
+	}
+	opts, ok := validatedArgs.(*IP6_Options)
+	if !ok {
+		return fmt.Errorf("Invalid Type: %T", validatedArgs)
+	}
+	zone, err := ReverseZone(opts.Prefix)
+	if err != nil {
+		return err
+	}
+	start_time := time.Now()
+	fmt.Printf(IP6_START_STRING, opts.Prefix, zone, opts.Nameserver, start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[PROGRESS]---------------------")
+
+	walker := NewIP6Walker(zone, opts.Nameserver, opts.Threads)
+	recs := walker.Walk()
+	color.Blue("[ IP6.ARPA Walk Results ]")
+	fmt.Printf("  Queries: %d | Abandoned subtrees: %d\n", walker.Queries(), len(walker.Abandoned))
+	recs.Print()
+	if len(walker.Abandoned) > 0 {
+		color.Yellow("[ Abandoned Subtrees ]")
+		for i, name := range walker.Abandoned {
+			if i == len(walker.Abandoned)-1 {
+				fmt.Printf("  |_____%s\n\n", name)
+				break
+			}
+			fmt.Printf("  | \t%s\n", name)
+		}
+	}
+
+	end_time := time.Now()
+	fmt.Printf(DNS_END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	return nil
+}
+
+// ReverseZone converts a nibble aligned IPv6 prefix into its ip6.arpa name.
+func ReverseZone(prefix string) (string, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", fmt.Errorf("Invalid Prefix: %v", err)
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 128 {
+		return "", fmt.Errorf("Invalid Prefix: %s is not IPv6", prefix)
+	}
+	if ones%IP6_BITS_STEP != 0 {
+		return "", fmt.Errorf("Invalid Prefix: /%d is not nibble aligned", ones)
+	}
+	full, err := dns.ReverseAddr(network.IP.String())
+	if err != nil {
+		return "", err
+	}
+	labels := dns.SplitDomainName(full)
+	nibbles := labels[IP6_NIBBLES-ones/IP6_BITS_STEP : IP6_NIBBLES]
+	if len(nibbles) == 0 {
+		return IP6_ARPA, nil
+	}
+	return strings.Join(nibbles, ".") + "." + IP6_ARPA, nil
+}
+
+// IP6Walker descends the ip6.arpa nibble tree, relying on RFC 8020 NXDOMAIN
+// answers for empty non-terminals to prune every branch that holds nothing.
+// Names that keep failing after the retries are listed in Abandoned.
+type IP6Walker struct {
+	Zone       string
+	Nameserver string
+	Threads    int
+	Retries    int
+	Records    *Records
+	Abandoned  []string
+	queries    int64
+	pending    sync.WaitGroup
+	queue      chan string
+	mu         sync.Mutex
+}
+
+func NewIP6Walker(zone, nameserver string, threads int) *IP6Walker {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	return &IP6Walker{
+		Zone:       dns.Fqdn(strings.ToLower(zone)),
+		Nameserver: nameserver,
+		Threads:    threads,
+		Retries:    IP6_RETRIES,
+		Records:    NewRecords(),
+		queue:      make(chan string, 100),
+	}
+}
+
+func (w *IP6Walker) Queries() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.queries
+}
+
+func (w *IP6Walker) Walk() *Records {
+	compliant, err := w.compliant()
+	if err != nil {
+		fmt.Printf("[WARNING] %s did not answer the NXDOMAIN probe under %s, walk aborted: %v\n", w.Nameserver, w.Zone, err)
+		return w.Records
+	}
+	if !compliant {
+		fmt.Printf("[WARNING] %s answers NOERROR for nonexistent names under %s, walk would not prune\n", w.Nameserver, w.Zone)
+		return w.Records
+	}
+	for i := 0; i < w.Threads; i++ {
+		go w.worker()
+	}
+	w.add(w.Zone)
+	w.pending.Wait()
+	close(w.queue)
+	sort.Strings(w.Abandoned)
+	return w.Records
+}
+
+// compliant checks that a name which cannot exist comes back as NXDOMAIN. A
+// probe that never gets an answer proves nothing and is returned as an error.
+func (w *IP6Walker) compliant() (bool, error) {
+	probe := w.Zone
+	for depth(probe) < IP6_NIBBLES {
+		probe = "f." + probe
+	}
+	probe = randomLabel(WILDCARD_LABEL_SIZE) + "." + probe
+	in, err := w.retry(probe)
+	if err != nil {
+		return false, err
+	}
+	return in.Rcode == dns.RcodeNameError, nil
+}
+
+func (w *IP6Walker) add(name string) {
+	w.pending.Add(1)
+	go func() {
+		w.queue <- name
+	}()
+}
+
+func (w *IP6Walker) worker() {
+	for name := range w.queue {
+		w.visit(name)
+		w.pending.Done()
+	}
+}
+
+func (w *IP6Walker) visit(name string) {
+	in, err := w.retry(name)
+	if err != nil {
+		fmt.Printf("[ERROR] %s: %v, subtree abandoned\n", name, err)
+		w.mu.Lock()
+		w.Abandoned = append(w.Abandoned, name)
+		w.mu.Unlock()
+		return
+	}
+	if in.Rcode == dns.RcodeNameError {
+		return
+	}
+	if in.Rcode != dns.RcodeSuccess {
+		fmt.Printf("[WARNING] %s: %s\n", name, dns.RcodeToString[in.Rcode])
+		return
+	}
+	for _, rr := range in.Answer {
+		if rr.Header().Rrtype != dns.TypePTR {
+			continue
+		}
+		w.Records.mu.Lock()
+		w.Records.Data[dns.TypePTR] = append(w.Records.Data[dns.TypePTR], rr)
+		w.Records.mu.Unlock()
+	}
+	if depth(name) >= IP6_NIBBLES {
+		return
+	}
+	for _, nibble := range IP6_CHILDREN {
+		w.add(string(nibble) + "." + name)
+	}
+}
+
+// retry repeats timed out and SERVFAIL queries with exponential backoff, a
+// single lost packet would otherwise drop everything below the name.
+func (w *IP6Walker) retry(name string) (*dns.Msg, error) {
+	var attempt int
+	for {
+		attempt++
+		in, err := w.query(name)
+		if err == nil && in.Rcode != dns.RcodeServerFailure {
+			return in, nil
+		}
+		if attempt > w.Retries {
+			if err == nil {
+				err = fmt.Errorf("%s", dns.RcodeToString[in.Rcode])
+			}
+			return nil, fmt.Errorf("%v after %d attempts", err, attempt)
+		}
+		time.Sleep(IP6_BACKOFF << (attempt - 1))
+	}
+}
+
+func (w *IP6Walker) query(name string) (*dns.Msg, error) {
+	w.mu.Lock()
+	w.queries++
+	w.mu.Unlock()
+	return exchange(name, dns.TypePTR, w.Nameserver)
+}
+
+// depth returns how many nibble labels sit in front of ip6.arpa.
+func depth(name string) int {
+	return dns.CountLabel(name) - dns.CountLabel(IP6_ARPA)
+}
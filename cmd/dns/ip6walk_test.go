@@ -0,0 +1,46 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReverseZone(t *testing.T) {
+	tests := []struct {
+		prefix string
+		zone   string
+		err    string
+	}{
+		{prefix: "2001:db8::/32", zone: "8.b.d.0.1.0.0.2.ip6.arpa."},
+		{prefix: "2001:db8:1234::/48", zone: "4.3.2.1.8.b.d.0.1.0.0.2.ip6.arpa."},
+		{prefix: "2001:db8:1234:5600::/56", zone: "6.5.4.3.2.1.8.b.d.0.1.0.0.2.ip6.arpa."},
+		// Host bits past the prefix are masked off
+		{prefix: "2001:db8::1/32", zone: "8.b.d.0.1.0.0.2.ip6.arpa."},
+		{prefix: "2001:db8::1/128", zone: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+		{prefix: "::/0", zone: IP6_ARPA},
+		{prefix: "2001:db8::/33", err: "not nibble aligned"},
+		{prefix: "192.0.2.0/24", err: "not IPv6"},
+		{prefix: "2001:db8::", err: "Invalid Prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			zone, err := ReverseZone(tt.prefix)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ReverseZone() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReverseZone() error = %v", err)
+			}
+			if zone != tt.zone {
+				t.Errorf("ReverseZone() = %s, want %s", zone, tt.zone)
+			}
+			if got := depth(zone) * IP6_BITS_STEP; !strings.HasSuffix(tt.prefix, "/"+strconv.Itoa(got)) {
+				t.Errorf("depth(%s) = %d nibbles, does not match %s", zone, depth(zone), tt.prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+const (
+	CATEGORY_INTERNAL_IP = "Internal IP"
+	CATEGORY_DISCLOSURE  = "Disclosure"
+)
+
+type addressRange struct {
+	Prefix netip.Prefix
+	Name   string
+}
+
+// Address space that should never be published in a public zone
+var internalRanges = []addressRange{
+	{netip.MustParsePrefix("10.0.0.0/8"), "RFC 1918 private"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "RFC 1918 private"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "RFC 1918 private"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "CGNAT shared"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "link-local"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "loopback"},
+	{netip.MustParsePrefix("fe80::/10"), "IPv6 link-local"},
+	{netip.MustParsePrefix("fc00::/7"), "IPv6 unique local"},
+	{netip.MustParsePrefix("::1/128"), "IPv6 loopback"},
+}
+
+// Suffixes of names that only resolve inside a network
+var internalSuffixes = []string{
+	"local.", "internal.", "intranet.", "corp.", "lan.", "home.", "home.arpa.", "localdomain.", "private.", "priv.",
+}
+
+var (
+	emailPattern    = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	hostnamePattern = regexp.MustCompile(`(?i)\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:local|internal|intranet|corp|lan|localdomain|home\.arpa)\b`)
+	ipv4Pattern     = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	secretPattern   = regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|private[_-]?key)\s*[=:]`)
+)
+
+func internalRange(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	for _, r := range internalRanges {
+		if r.Prefix.Contains(ip.Unmap()) {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+func internalName(name string) bool {
+	name = strings.ToLower(dns.Fqdn(name))
+	for _, suffix := range internalSuffixes {
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// LeakAudit looks through everything already collected for internal
+// addresses and records that reveal software, people or internal names.
+type LeakAudit struct {
+	Domain   string
+	Findings *Findings
+	seen     map[string]bool
+}
+
+func NewLeakAudit(domain string) *LeakAudit {
+	return &LeakAudit{
+		Domain:   dns.Fqdn(strings.ToLower(domain)),
+		Findings: NewFindings(),
+		seen:     make(map[string]bool),
+	}
+}
+
+// AddAddresses flags internal addresses among resolved addresses and the names pointing at them.
+func (l *LeakAudit) AddAddresses(addresses map[string][]string) {
+	addrs := make([]string, 0, len(addresses))
+	for addr := range addresses {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		kind := internalRange(addr)
+		if kind == "" {
+			continue
+		}
+		names := addresses[addr]
+		sort.Strings(names)
+		for _, name := range names {
+			l.add(SEVERITY_LOW, CATEGORY_INTERNAL_IP, name, fmt.Sprintf("resolves to %s address %s", kind, addr))
+		}
+	}
+}
+
+// AddRecords checks the records of a record check or a zone transfer.
+func (l *LeakAudit) AddRecords(r *Records) {
+	r.mu.Lock()
+	rrs := make([]dns.RR, 0)
+	for _, list := range r.Data {
+		rrs = append(rrs, list...)
+	}
+	r.mu.Unlock()
+	sort.Slice(rrs, func(i, j int) bool { return rrs[i].String() < rrs[j].String() })
+	for _, rr := range rrs {
+		l.check(rr)
+	}
+}
+
+func (l *LeakAudit) check(rr dns.RR) {
+	owner := strings.ToLower(rr.Header().Name)
+	switch v := rr.(type) {
+	case *dns.HINFO:
+		l.add(SEVERITY_LOW, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("HINFO reveals hardware %q and OS %q", v.Cpu, v.Os))
+	case *dns.RP:
+		l.add(SEVERITY_LOW, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("RP names a responsible person: mailbox %s, details at %s", mboxAddress(v.Mbox), v.Txt))
+	case *dns.LOC:
+		l.add(SEVERITY_INFO, CATEGORY_DISCLOSURE, owner, "LOC publishes a physical location: "+strings.TrimPrefix(v.String(), v.Hdr.String()))
+	case *dns.TXT:
+		l.checkText(owner, strings.Join(v.Txt, ""))
+	case *dns.CNAME:
+		l.checkTarget(owner, "CNAME", v.Target)
+	case *dns.MX:
+		l.checkTarget(owner, "MX", v.Mx)
+	case *dns.NS:
+		l.checkTarget(owner, "NS", v.Ns)
+	case *dns.SRV:
+		l.checkTarget(owner, "SRV", v.Target)
+	case *dns.PTR:
+		l.checkTarget(owner, "PTR", v.Ptr)
+	}
+}
+
+func (l *LeakAudit) checkTarget(owner, rrtype, target string) {
+	if internalName(target) {
+		l.add(SEVERITY_LOW, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("%s points at internal name %s", rrtype, strings.ToLower(target)))
+	}
+}
+
+// checkText looks for secrets, internal names and addresses, and mailboxes in TXT data.
+func (l *LeakAudit) checkText(owner, text string) {
+	if secretPattern.MatchString(text) {
+		l.add(SEVERITY_MEDIUM, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("TXT looks like it carries a credential: %q", text))
+	}
+	for _, host := range hostnamePattern.FindAllString(text, -1) {
+		l.add(SEVERITY_LOW, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("TXT mentions internal host %s", strings.ToLower(host)))
+	}
+	for _, addr := range ipv4Pattern.FindAllString(text, -1) {
+		if kind := internalRange(addr); kind != "" {
+			l.add(SEVERITY_LOW, CATEGORY_INTERNAL_IP, owner, fmt.Sprintf("TXT mentions %s address %s", kind, addr))
+		}
+	}
+	// Report addresses are expected in DMARC and TLS-RPT records
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(lower, "v=dmarc1") || strings.HasPrefix(lower, "v=tlsrptv1") {
+		return
+	}
+	for _, email := range emailPattern.FindAllString(text, -1) {
+		l.add(SEVERITY_INFO, CATEGORY_DISCLOSURE, owner, fmt.Sprintf("TXT publishes email address %s", strings.ToLower(email)))
+	}
+}
+
+// add records a finding once, the same record often arrives from several sources.
+func (l *LeakAudit) add(severity, category, subject, detail string) {
+	key := subject + "\t" + detail
+	if l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+	l.Findings.Add(severity, category, subject, detail)
+}
+
+// mboxAddress turns an RP or SOA mailbox name into an email address.
+func mboxAddress(mbox string) string {
+	mbox = strings.TrimSuffix(mbox, ".")
+	if mbox == "" {
+		return "-"
+	}
+	for i := 0; i < len(mbox); i++ {
+		switch mbox[i] {
+		case '\\':
+			i++
+		case '.':
+			return strings.ReplaceAll(mbox[:i], `\.`, ".") + "@" + mbox[i+1:]
+		}
+	}
+	return mbox
+}
+
+func (l *LeakAudit) Print() {
+	color.Blue("[ Information Leakage ]")
+	l.Findings.Print()
+}
@@ -0,0 +1,24 @@
+package dns
+
+import "testing"
+
+func TestMboxAddress(t *testing.T) {
+	tests := []struct {
+		mbox string
+		want string
+	}{
+		{"hostmaster.example.com.", "hostmaster@example.com"},
+		{"admin.example.com", "admin@example.com"},
+		// An escaped dot belongs to the local part
+		{`john\.doe.example.com.`, "john.doe@example.com"},
+		{`a\.b\.c.mail.example.com.`, "a.b.c@mail.example.com"},
+		{"root.", "root"},
+		{".", "-"},
+		{"", "-"},
+	}
+	for _, tt := range tests {
+		if got := mboxAddress(tt.mbox); got != tt.want {
+			t.Errorf("mboxAddress(%q) = %q, want %q", tt.mbox, got, tt.want)
+		}
+	}
+}
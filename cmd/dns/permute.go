@@ -0,0 +1,294 @@
+package dns
+
+import (
+	"math/rand"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	DEFAULT_PERMUTE_ROUNDS = 2
+	// Largest amount a number found in a label is incremented or decremented by
+	PERMUTE_NUMBER_RANGE = 3
+	WILDCARD_LABEL_SIZE  = 12
+)
+
+var permuteWords = []string{
+	"admin", "api", "app", "auth", "backup", "beta", "corp", "db", "demo",
+	"dev", "git", "internal", "mail", "new", "old", "portal", "prod", "qa",
+	"stage", "staging", "test", "uat", "vpn", "web",
+}
+
+var environmentWords = []string{
+	"dev", "develop", "development", "test", "testing", "qa", "uat",
+	"stage", "staging", "preprod", "prod", "production", "sandbox",
+}
+
+var digitRun = regexp.MustCompile(`[0-9]+`)
+
+// Permuter generates altdns/dnsgen style mutations of known names and keeps
+// resolving whatever hits come back for a bounded number of rounds.
+type Permuter struct {
+	Domain     string
+	Nameserver string
+	Threads    int
+	Rounds     int
+	Words      []string
+	Hits       *Inventory
+	tried      map[string]bool
+	wildcards  *sync.Map
+}
+
+func NewPermuter(domain, nameserver string, threads, rounds int, words []string) *Permuter {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	if rounds <= 0 {
+		rounds = DEFAULT_PERMUTE_ROUNDS
+	}
+	if len(words) == 0 {
+		words = permuteWords
+	}
+	return &Permuter{
+		Domain:     dns.Fqdn(strings.ToLower(domain)),
+		Nameserver: nameserver,
+		Threads:    threads,
+		Rounds:     rounds,
+		Words:      words,
+		Hits:       NewInventory(),
+		tried:      make(map[string]bool),
+		wildcards:  &sync.Map{},
+	}
+}
+
+func (p *Permuter) Run(known []string) *Inventory {
+	seeds := make([]string, 0, len(known))
+	for _, name := range known {
+		name = dns.Fqdn(strings.ToLower(name))
+		if !dns.IsSubDomain(p.Domain, name) {
+			continue
+		}
+		p.tried[name] = true
+		seeds = append(seeds, name)
+	}
+
+	for round := 0; round < p.Rounds && len(seeds) > 0; round++ {
+		candidates := make([]string, 0)
+		for _, name := range seeds {
+			for _, c := range p.Mutate(name) {
+				if p.tried[c] {
+					continue
+				}
+				p.tried[c] = true
+				candidates = append(candidates, c)
+			}
+		}
+		seeds = p.resolveAll(candidates)
+	}
+	return p.Hits
+}
+
+// Mutate returns every permutation of a single in-zone name.
+func (p *Permuter) Mutate(name string) []string {
+	sub := strings.TrimSuffix(strings.TrimSuffix(name, p.Domain), ".")
+	labels := make([]string, 0)
+	if sub != "" {
+		labels = strings.Split(sub, ".")
+	}
+	out := make([]string, 0)
+	add := func(parts []string) {
+		out = append(out, strings.Join(parts, ".")+"."+p.Domain)
+	}
+
+	// Word insertions
+	for _, word := range p.Words {
+		for i := 0; i <= len(labels); i++ {
+			add(slices.Insert(slices.Clone(labels), i, word))
+		}
+		for i, label := range labels {
+			for _, joined := range []string{word + "-" + label, label + "-" + word, word + label, label + word} {
+				parts := slices.Clone(labels)
+				parts[i] = joined
+				add(parts)
+			}
+		}
+	}
+
+	for i, label := range labels {
+		// Number increments
+		for _, loc := range digitRun.FindAllStringIndex(label, -1) {
+			n, err := strconv.Atoi(label[loc[0]:loc[1]])
+			if err != nil {
+				continue
+			}
+			for delta := -PERMUTE_NUMBER_RANGE; delta <= PERMUTE_NUMBER_RANGE; delta++ {
+				if delta == 0 || n+delta < 0 {
+					continue
+				}
+				parts := slices.Clone(labels)
+				parts[i] = label[:loc[0]] + strconv.Itoa(n+delta) + label[loc[1]:]
+				add(parts)
+			}
+		}
+
+		// Environment swaps
+		tokens := strings.Split(label, "-")
+		for t, token := range tokens {
+			bare := digitRun.ReplaceAllString(token, "")
+			if !slices.Contains(environmentWords, bare) {
+				continue
+			}
+			for _, env := range environmentWords {
+				if env == bare {
+					continue
+				}
+				swapped := slices.Clone(tokens)
+				swapped[t] = strings.Replace(token, bare, env, 1)
+				parts := slices.Clone(labels)
+				parts[i] = strings.Join(swapped, "-")
+				add(parts)
+			}
+		}
+
+		// Dash to dot splits
+		if len(tokens) > 1 {
+			parts := slices.Clone(labels)
+			add(slices.Replace(parts, i, i+1, tokens...))
+		}
+	}
+
+	// Dash and dot joins of neighbouring labels
+	for i := 0; i+1 < len(labels); i++ {
+		for _, sep := range []string{"-", ""} {
+			parts := slices.Clone(labels)
+			add(slices.Replace(parts, i, i+2, labels[i]+sep+labels[i+1]))
+		}
+	}
+
+	sort.Strings(out)
+	out = slices.Compact(out)
+	return slices.DeleteFunc(out, func(c string) bool {
+		_, ok := dns.IsDomainName(c)
+		return !ok || c == name
+	})
+}
+
+func (p *Permuter) resolveAll(candidates []string) []string {
+	tasks := make(chan string, 100)
+	found := make([]string, 0)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	go func() {
+		for _, c := range candidates {
+			tasks <- c
+		}
+		close(tasks)
+	}()
+	for i := 0; i < p.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range tasks {
+				host := p.resolve(name)
+				if host == nil {
+					continue
+				}
+				p.Hits.mu.Lock()
+				p.Hits.Hosts[name] = host
+				p.Hits.mu.Unlock()
+				mu.Lock()
+				found = append(found, name)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return found
+}
+
+func (p *Permuter) resolve(name string) *Host {
+	host := &Host{Name: name, Source: "permutation"}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		rrs, err := lookup(name, qtype, p.Nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			switch v := rr.(type) {
+			case *dns.A:
+				host.Addrs = append(host.Addrs, v.A.String())
+			case *dns.AAAA:
+				host.Addrs = append(host.Addrs, v.AAAA.String())
+			case *dns.CNAME:
+				host.Chain = append(host.Chain, strings.ToLower(v.Target))
+			}
+		}
+	}
+	if len(host.Addrs) == 0 {
+		return nil
+	}
+	sort.Strings(host.Addrs)
+	host.Addrs = slices.Compact(host.Addrs)
+	host.Chain = slices.Compact(host.Chain)
+
+	wildcard := p.wildcard(parentDomain(name))
+	for _, addr := range host.Addrs {
+		if !slices.Contains(wildcard, addr) {
+			return host
+		}
+	}
+	return nil
+}
+
+func (p *Permuter) wildcard(parent string) []string {
+	if cached, ok := p.wildcards.Load(parent); ok {
+		return cached.([]string)
+	}
+	cached, _ := p.wildcards.LoadOrStore(parent, probeWildcard(parent, p.Nameserver))
+	return cached.([]string)
+}
+
+// probeWildcard resolves a random label under parent and returns whatever
+// addresses a wildcard record hands back for it.
+func probeWildcard(parent, nameserver string) []string {
+	addrs := make([]string, 0)
+	probe := randomLabel(WILDCARD_LABEL_SIZE) + "." + dns.Fqdn(parent)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		rrs, err := lookup(probe, qtype, nameserver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range rrs {
+			switch v := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, v.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, v.AAAA.String())
+			}
+		}
+	}
+	return addrs
+}
+
+func parentDomain(name string) string {
+	if i := strings.Index(name, "."); i >= 0 && i+1 < len(name) {
+		return name[i+1:]
+	}
+	return "."
+}
+
+func randomLabel(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
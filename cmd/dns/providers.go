@@ -0,0 +1,309 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+// Where a signature looks: TXT values by prefix, SPF includes, CNAME, MX and
+// SRV targets by suffix
+const (
+	SIG_TXT   = "TXT"
+	SIG_SPF   = "SPF"
+	SIG_CNAME = "CNAME"
+	SIG_MX    = "MX"
+	SIG_SRV   = "SRV"
+)
+
+type Signature struct {
+	Provider string
+	Category string
+	Kind     string
+	Pattern  string
+}
+
+// Vendor fingerprints, verification tokens are matched case insensitively on their prefix
+var providerSignatures = []Signature{
+	// Email
+	{"Google Workspace", "Email", SIG_MX, ".google.com."},
+	{"Google Workspace", "Email", SIG_MX, ".googlemail.com."},
+	{"Google Workspace", "Email", SIG_SPF, "_spf.google.com"},
+	{"Microsoft 365", "Email", SIG_MX, ".mail.protection.outlook.com."},
+	{"Microsoft 365", "Email", SIG_SPF, "spf.protection.outlook.com"},
+	{"Microsoft 365", "Email", SIG_CNAME, "autodiscover.outlook.com."},
+	{"Microsoft 365", "Email", SIG_TXT, "ms="},
+	{"Microsoft 365", "Collaboration", SIG_CNAME, "lyncdiscover.infra.lync.com."},
+	{"Microsoft 365", "Collaboration", SIG_CNAME, "sipdir.online.lync.com."},
+	{"Microsoft 365", "Collaboration", SIG_SRV, "sipfed.online.lync.com."},
+	{"Microsoft 365", "Identity", SIG_CNAME, "enterpriseenrollment.manage.microsoft.com."},
+	{"Microsoft 365", "Identity", SIG_CNAME, "enterpriseregistration.windows.net."},
+	{"Zoho Mail", "Email", SIG_MX, ".zoho.com."},
+	{"Zoho Mail", "Email", SIG_MX, ".zoho.eu."},
+	{"Zoho", "Verification", SIG_TXT, "zoho-verification="},
+	{"Proofpoint", "Email Security", SIG_MX, ".pphosted.com."},
+	{"Proofpoint", "Email Security", SIG_SPF, "pphosted.com"},
+	{"Mimecast", "Email Security", SIG_MX, ".mimecast.com."},
+	{"Mimecast", "Email Security", SIG_SPF, "_netblocks.mimecast.com"},
+	{"Barracuda", "Email Security", SIG_MX, ".barracudanetworks.com."},
+	{"Cisco Secure Email", "Email Security", SIG_MX, ".iphmx.com."},
+	{"Amazon SES", "Email", SIG_SPF, "amazonses.com"},
+	{"Amazon SES", "Email", SIG_TXT, "amazonses:"},
+	{"SendGrid", "Email", SIG_SPF, "sendgrid.net"},
+	{"SendGrid", "Email", SIG_CNAME, ".sendgrid.net."},
+	{"Mailgun", "Email", SIG_SPF, "mailgun.org"},
+	{"Mailgun", "Email", SIG_MX, ".mailgun.org."},
+	{"Mailchimp", "Marketing", SIG_SPF, "servers.mcsv.net"},
+	{"Mailchimp", "Marketing", SIG_CNAME, ".mcsv.net."},
+	{"Mandrill", "Email", SIG_SPF, "spf.mandrillapp.com"},
+	{"Postmark", "Email", SIG_SPF, "spf.mtasv.net"},
+	{"SparkPost", "Email", SIG_SPF, "sparkpostmail.com"},
+	// Identity
+	{"Okta", "Identity", SIG_CNAME, ".okta.com."},
+	{"Okta", "Identity", SIG_CNAME, ".oktapreview.com."},
+	{"Auth0", "Identity", SIG_CNAME, ".auth0.com."},
+	{"OneLogin", "Identity", SIG_CNAME, ".onelogin.com."},
+	{"Duo", "Identity", SIG_TXT, "duo_sso_verification="},
+	// Collaboration
+	{"Atlassian", "Collaboration", SIG_TXT, "atlassian-domain-verification="},
+	{"Atlassian", "Collaboration", SIG_CNAME, ".atlassian.net."},
+	{"Slack", "Collaboration", SIG_TXT, "slack-domain-verification="},
+	{"Zoom", "Collaboration", SIG_TXT, "zoom_verify_"},
+	{"Webex", "Collaboration", SIG_TXT, "webexdomainverification"},
+	{"Dropbox", "Collaboration", SIG_TXT, "dropbox-domain-verification="},
+	{"Box", "Collaboration", SIG_TXT, "box-domain-verification="},
+	{"Miro", "Collaboration", SIG_TXT, "miro-verification="},
+	{"Notion", "Collaboration", SIG_TXT, "notion-domain-verification="},
+	{"DocuSign", "Collaboration", SIG_TXT, "docusign="},
+	{"Adobe", "Collaboration", SIG_TXT, "adobe-idp-site-verification="},
+	{"Adobe", "Collaboration", SIG_TXT, "adobe-sign-verification="},
+	{"Apple", "Verification", SIG_TXT, "apple-domain-verification="},
+	{"Facebook", "Marketing", SIG_TXT, "facebook-domain-verification="},
+	{"Google", "Verification", SIG_TXT, "google-site-verification="},
+	{"Cisco", "Verification", SIG_TXT, "cisco-ci-domain-verification="},
+	{"GlobalSign", "Certificates", SIG_TXT, "globalsign-domain-verification="},
+	{"GlobalSign", "Certificates", SIG_TXT, "_globalsign-domain-verification="},
+	// CRM, support and marketing
+	{"Salesforce", "CRM", SIG_SPF, "_spf.salesforce.com"},
+	{"Salesforce", "CRM", SIG_CNAME, ".force.com."},
+	{"Salesforce", "CRM", SIG_CNAME, ".salesforce.com."},
+	{"HubSpot", "Marketing", SIG_TXT, "hubspot-developer-verification="},
+	{"HubSpot", "Marketing", SIG_CNAME, ".hubspot.net."},
+	{"HubSpot", "Marketing", SIG_CNAME, ".hs-sites.com."},
+	{"Marketo", "Marketing", SIG_CNAME, ".mktoweb.com."},
+	{"Pardot", "Marketing", SIG_CNAME, ".pardot.com."},
+	{"Zendesk", "Support", SIG_CNAME, ".zendesk.com."},
+	{"Zendesk", "Support", SIG_SPF, "mail.zendesk.com"},
+	{"Zendesk", "Support", SIG_TXT, "zendeskverification="},
+	{"Freshdesk", "Support", SIG_CNAME, ".freshdesk.com."},
+	{"Intercom", "Support", SIG_CNAME, ".intercom.help."},
+	{"ServiceNow", "Support", SIG_CNAME, ".service-now.com."},
+	{"Statuspage", "Support", SIG_CNAME, ".stspg-customer.com."},
+	// Hosting and CDN
+	{"AWS", "Hosting", SIG_CNAME, ".amazonaws.com."},
+	{"AWS CloudFront", "CDN", SIG_CNAME, ".cloudfront.net."},
+	{"AWS", "Certificates", SIG_CNAME, ".acm-validations.aws."},
+	{"Azure", "Hosting", SIG_CNAME, ".azurewebsites.net."},
+	{"Azure", "Hosting", SIG_CNAME, ".cloudapp.azure.com."},
+	{"Azure", "Hosting", SIG_CNAME, ".blob.core.windows.net."},
+	{"Azure Front Door", "CDN", SIG_CNAME, ".azurefd.net."},
+	{"Azure CDN", "CDN", SIG_CNAME, ".azureedge.net."},
+	{"Google Cloud", "Hosting", SIG_CNAME, ".appspot.com."},
+	{"Google Cloud", "Hosting", SIG_CNAME, "ghs.googlehosted.com."},
+	{"Cloudflare", "CDN", SIG_CNAME, ".cdn.cloudflare.net."},
+	{"Akamai", "CDN", SIG_CNAME, ".edgekey.net."},
+	{"Akamai", "CDN", SIG_CNAME, ".edgesuite.net."},
+	{"Fastly", "CDN", SIG_CNAME, ".fastly.net."},
+	{"Heroku", "Hosting", SIG_CNAME, ".herokuapp.com."},
+	{"Heroku", "Hosting", SIG_CNAME, ".herokudns.com."},
+	{"GitHub Pages", "Hosting", SIG_CNAME, ".github.io."},
+	{"Netlify", "Hosting", SIG_CNAME, ".netlify.app."},
+	{"Vercel", "Hosting", SIG_CNAME, ".vercel-dns.com."},
+	{"Shopify", "Hosting", SIG_CNAME, ".myshopify.com."},
+	{"WordPress.com", "Hosting", SIG_CNAME, ".wordpress.com."},
+	{"Squarespace", "Hosting", SIG_CNAME, ".squarespace.com."},
+	{"Wix", "Hosting", SIG_CNAME, ".wixdns.net."},
+	{"Webflow", "Hosting", SIG_CNAME, ".webflow.io."},
+	{"Pantheon", "Hosting", SIG_CNAME, ".pantheonsite.io."},
+	{"WP Engine", "Hosting", SIG_CNAME, ".wpengine.com."},
+}
+
+type ProviderMatch struct {
+	Provider string
+	Category string
+	Evidence []string
+}
+
+// ProviderAnalyzer maps collected records onto known SaaS and hosting
+// vendors to outline the third-party services a domain relies on.
+type ProviderAnalyzer struct {
+	Domain  string
+	Matches map[string]*ProviderMatch
+}
+
+func NewProviderAnalyzer(domain string) *ProviderAnalyzer {
+	return &ProviderAnalyzer{
+		Domain:  dns.Fqdn(strings.ToLower(domain)),
+		Matches: make(map[string]*ProviderMatch),
+	}
+}
+
+func (p *ProviderAnalyzer) AddRecords(r *Records) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, list := range r.Data {
+		for _, rr := range list {
+			p.check(rr)
+		}
+	}
+}
+
+// AddInventory matches the CNAME chains of hosts found by brute forcing,
+// expansion, permutation and certificates.
+func (p *ProviderAnalyzer) AddInventory(inv *Inventory) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	names := make([]string, 0, len(inv.Hosts))
+	for name := range inv.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		owner := dns.Fqdn(strings.ToLower(name))
+		if !dns.IsSubDomain(p.Domain, owner) {
+			continue
+		}
+		for _, target := range inv.Hosts[name].Chain {
+			p.match(SIG_CNAME, target, fmt.Sprintf("%s CNAME %s", owner, strings.ToLower(target)))
+			owner = dns.Fqdn(strings.ToLower(target))
+		}
+	}
+}
+
+// check skips out of zone data a transfer may carry, it says nothing about this domain.
+func (p *ProviderAnalyzer) check(rr dns.RR) {
+	owner := strings.ToLower(rr.Header().Name)
+	if !dns.IsSubDomain(p.Domain, owner) {
+		return
+	}
+	switch v := rr.(type) {
+	case *dns.TXT:
+		text := strings.Join(v.Txt, "")
+		lower := strings.ToLower(text)
+		if strings.HasPrefix(lower, "v=spf1") {
+			for _, include := range spfIncludes(lower) {
+				p.match(SIG_SPF, include, fmt.Sprintf("%s SPF include:%s", owner, include))
+			}
+			return
+		}
+		p.match(SIG_TXT, lower, fmt.Sprintf("%s TXT %q", owner, text))
+	case *dns.CNAME:
+		p.match(SIG_CNAME, v.Target, fmt.Sprintf("%s CNAME %s", owner, strings.ToLower(v.Target)))
+	case *dns.MX:
+		p.match(SIG_MX, v.Mx, fmt.Sprintf("%s MX %s", owner, strings.ToLower(v.Mx)))
+	case *dns.SRV:
+		p.match(SIG_SRV, v.Target, fmt.Sprintf("%s SRV %s", owner, strings.ToLower(v.Target)))
+	case *dns.NS:
+		if provider := matchProvider(v.Ns); provider != "" {
+			p.add(provider, "DNS Hosting", fmt.Sprintf("%s NS %s", owner, strings.ToLower(v.Ns)))
+		}
+	}
+}
+
+// match compares a value against every signature of a kind. CNAME, MX and
+// SRV targets match on a suffix or the exact name, the rest on a prefix.
+func (p *ProviderAnalyzer) match(kind, value, evidence string) {
+	value = strings.ToLower(value)
+	for _, sig := range providerSignatures {
+		if sig.Kind != kind {
+			continue
+		}
+		var ok bool
+		switch kind {
+		case SIG_CNAME, SIG_MX, SIG_SRV:
+			pattern := sig.Pattern
+			if !strings.HasPrefix(pattern, ".") {
+				pattern = "." + pattern
+			}
+			ok = strings.HasSuffix("."+dns.Fqdn(value), pattern)
+		case SIG_SPF:
+			ok = value == sig.Pattern || strings.HasSuffix(value, "."+sig.Pattern)
+		default:
+			ok = strings.HasPrefix(value, sig.Pattern)
+		}
+		if ok {
+			p.add(sig.Provider, sig.Category, evidence)
+		}
+	}
+}
+
+func (p *ProviderAnalyzer) add(provider, category, evidence string) {
+	key := category + "\t" + provider
+	match, ok := p.Matches[key]
+	if !ok {
+		match = &ProviderMatch{Provider: provider, Category: category, Evidence: make([]string, 0)}
+		p.Matches[key] = match
+	}
+	if !slices.Contains(match.Evidence, evidence) {
+		match.Evidence = append(match.Evidence, evidence)
+	}
+}
+
+// spfIncludes returns the include and redirect domains of an SPF record.
+func spfIncludes(spf string) []string {
+	includes := make([]string, 0)
+	for _, term := range strings.Fields(spf) {
+		term = strings.TrimLeft(term, "+-~?")
+		for _, prefix := range []string{"include:", "redirect="} {
+			if strings.HasPrefix(term, prefix) {
+				includes = append(includes, strings.TrimSuffix(strings.TrimPrefix(term, prefix), "."))
+			}
+		}
+	}
+	return includes
+}
+
+// Providers returns the matches sorted by category and provider.
+func (p *ProviderAnalyzer) Providers() []*ProviderMatch {
+	matches := make([]*ProviderMatch, 0, len(p.Matches))
+	for _, match := range p.Matches {
+		sort.Strings(match.Evidence)
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Category != matches[j].Category {
+			return matches[i].Category < matches[j].Category
+		}
+		return matches[i].Provider < matches[j].Provider
+	})
+	return matches
+}
+
+func (p *ProviderAnalyzer) Print() {
+	color.Blue("[ Third-Party Services ]")
+	matches := p.Providers()
+	if len(matches) == 0 {
+		fmt.Printf("  |_____No known providers\n\n")
+		return
+	}
+	category := ""
+	for i, match := range matches {
+		if match.Category != category {
+			category = match.Category
+			color.Yellow("  [ %s ]", category)
+		}
+		last := i == len(matches)-1 || matches[i+1].Category != category
+		fmt.Printf("  | \t%s\n", match.Provider)
+		for j, evidence := range match.Evidence {
+			if last && j == len(match.Evidence)-1 {
+				fmt.Printf("  |_____\t%s\n\n", evidence)
+				break
+			}
+			fmt.Printf("  | \t\t%s\n", evidence)
+		}
+	}
+}
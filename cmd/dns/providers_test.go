@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSPFIncludes(t *testing.T) {
+	tests := []struct {
+		spf  string
+		want []string
+	}{
+		{"v=spf1 include:_spf.google.com ~all", []string{"_spf.google.com"}},
+		{"v=spf1 ip4:192.0.2.0/24 include:spf.protection.outlook.com include:sendgrid.net -all", []string{"spf.protection.outlook.com", "sendgrid.net"}},
+		// Qualifiers and a trailing dot are dropped
+		{"v=spf1 +include:mail.zendesk.com. ?include:servers.mcsv.net ~all", []string{"mail.zendesk.com", "servers.mcsv.net"}},
+		{"v=spf1 redirect=_spf.salesforce.com", []string{"_spf.salesforce.com"}},
+		{"v=spf1 a mx ip6:2001:db8::/32 -all", []string{}},
+		// exists: and ptr: name hosts but are not includes
+		{"v=spf1 exists:%{i}.spf.example.com ptr:example.com -all", []string{}},
+	}
+	for _, tt := range tests {
+		if got := spfIncludes(tt.spf); !slices.Equal(got, tt.want) {
+			t.Errorf("spfIncludes(%q) = %q, want %q", tt.spf, got, tt.want)
+		}
+	}
+}
+
+func TestProviderMatch(t *testing.T) {
+	tests := []struct {
+		kind     string
+		value    string
+		provider string
+	}{
+		{SIG_CNAME, "acme.zendesk.com.", "Zendesk"},
+		{SIG_CNAME, "ACME.Zendesk.COM", "Zendesk"},
+		// The suffix has to start at a label boundary
+		{SIG_CNAME, "acme.notzendesk.com.", ""},
+		// Patterns without a leading dot also match the exact name
+		{SIG_CNAME, "autodiscover.outlook.com.", "Microsoft 365"},
+		{SIG_CNAME, "ghs.googlehosted.com", "Google Cloud"},
+		{SIG_MX, "example-com.mail.protection.outlook.com.", "Microsoft 365"},
+		{SIG_MX, "aspmx.l.google.com.", "Google Workspace"},
+		{SIG_SRV, "sipfed.online.lync.com.", "Microsoft 365"},
+		// A CNAME signature does not fire for an MX target
+		{SIG_MX, "acme.zendesk.com.", ""},
+		{SIG_SPF, "_spf.google.com", "Google Workspace"},
+		{SIG_SPF, "eu.mailgun.org", "Mailgun"},
+		{SIG_SPF, "notmailgun.org", ""},
+		{SIG_TXT, "google-site-verification=abc123", "Google"},
+		{SIG_TXT, "ms=ms123456", "Microsoft 365"},
+		{SIG_TXT, "verification google-site-verification=abc", ""},
+	}
+	for _, tt := range tests {
+		p := NewProviderAnalyzer("example.com")
+		p.match(tt.kind, tt.value, "evidence")
+		providers := make([]string, 0)
+		for _, match := range p.Providers() {
+			providers = append(providers, match.Provider)
+		}
+		switch {
+		case tt.provider == "" && len(providers) > 0:
+			t.Errorf("%s %s matched %v, want none", tt.kind, tt.value, providers)
+		case tt.provider != "" && !slices.Contains(providers, tt.provider):
+			t.Errorf("%s %s matched %v, want %s", tt.kind, tt.value, providers, tt.provider)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package dns
+
+import "sync"
+
+// frontier is an unbounded, deduplicated work queue. Pop blocks until there is
+// work or until the queue is empty while no popped item is still being worked
+// on, at which point every caller gets false and the run is complete.
+type frontier[T any] struct {
+	items  []T
+	seen   map[string]bool
+	active int
+	mu     sync.Mutex
+	cond   *sync.Cond
+}
+
+func newFrontier[T any]() *frontier[T] {
+	f := &frontier[T]{seen: make(map[string]bool)}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Push queues item unless key was pushed before.
+func (f *frontier[T]) Push(key string, item T) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.seen[key] {
+		return false
+	}
+	f.seen[key] = true
+	f.items = append(f.items, item)
+	f.cond.Signal()
+	return true
+}
+
+func (f *frontier[T]) Pop() (T, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.items) == 0 {
+		if f.active == 0 {
+			f.cond.Broadcast()
+			var zero T
+			return zero, false
+		}
+		f.cond.Wait()
+	}
+	item := f.items[0]
+	f.items = f.items[1:]
+	f.active++
+	return item, true
+}
+
+// Done marks an item returned by Pop as finished.
+func (f *frontier[T]) Done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+	if f.active == 0 && len(f.items) == 0 {
+		f.cond.Broadcast()
+	}
+}
+
+func (f *frontier[T]) Seen() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.seen)
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package dns
+
+// The race detector slows the engine down too much for a throughput floor
+const raceEnabled = true
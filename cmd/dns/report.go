@@ -0,0 +1,545 @@
+package dns
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/utils"
+)
+
+const DNS_DOMAIN_STRING = `
+[====== %s ======]
+`
+
+// DomainReport holds everything gathered for one domain so that domains can
+// run concurrently and still be printed one section at a time.
+type DomainReport struct {
+	Domain       string
+	Records      *Records
+	AXFR         *AXFR
+	DNSSEC       *DNSSECAudit
+	Delegation   *DelegationAudit
+	Dangling     *DanglingAudit
+	AD           *ADDiscovery
+	DNSSD        *DNSSDBrowser
+	Siblings     *SiblingAudit
+	Leaks        *LeakAudit
+	Providers    *ProviderAnalyzer
+	Mail         *MailHarvester
+	EmailFile    string
+	Brute        *Inventory
+	BruteStats   string
+	Inventory    *Inventory
+	Permutations *Inventory
+	Certs        *CertHarvester
+	CertHosts    *Inventory
+	ECS          *ECSProbe
+	LoadBalance  *LoadBalanceProbe
+	Enrichment   []*Enrichment
+	Graph        *Graph
+	GraphFile    string
+	SnapshotFile string
+	Wildcard     []string
+	Discovered   []string
+	Addresses    map[string][]string
+	Err          error
+	Took         time.Duration
+}
+
+// runDomain walks one domain through every enabled stage. A failing stage
+// stops the domain and is kept in the report instead of aborting the batch.
+func runDomain(opts *DNS_Options, domain string, recordTypes []uint16, enricher *Enricher, graphFile, emailFile string) *DomainReport {
+	start := time.Now()
+	domain = dns.Fqdn(strings.ToLower(domain))
+	ns := opts.Nameserver
+	report := &DomainReport{
+		Domain:    domain,
+		Records:   NewRecords(),
+		Graph:     NewGraph(domain),
+		GraphFile: graphFile,
+	}
+	defer func() {
+		sort.Strings(report.Discovered)
+		report.Discovered = slices.Compact(report.Discovered)
+		report.Took = time.Since(start)
+	}()
+
+	report.Records.CheckAllRecords(domain, ns, recordTypes)
+	report.Discovered = append(report.Records.Names(), report.Records.Targets()...)
+	report.Addresses = report.Records.Addresses()
+	report.Graph.AddRecords(report.Records)
+	report.Wildcard = probeWildcard(domain, ns)
+
+	if slices.Contains(recordTypes, dns.TypeAXFR) {
+		report.AXFR = NewAXFR(opts.AXFRWorkers, opts.AXFRDepth)
+		report.AXFR.ZoneTransfer(domain, ns, report.Records.NameServers())
+		report.add(report.AXFR.Names(), report.AXFR.Addresses())
+		report.Graph.AddTransfers(report.AXFR)
+	}
+	if opts.DNSSEC {
+		report.DNSSEC = NewDNSSECAudit(domain, ns)
+		report.DNSSEC.Run()
+	}
+	if opts.Delegation {
+		report.Delegation = NewDelegationAudit(domain, ns)
+		report.Delegation.Run()
+	}
+	if opts.Dangling {
+		report.Dangling = NewDanglingAudit(domain, ns)
+		for _, rec := range report.collected() {
+			report.Dangling.AddRecords(rec)
+		}
+		report.Dangling.Run()
+	}
+	if opts.AD {
+		report.AD = NewADDiscovery(domain, ns, opts.Threads, opts.Time.ToTime())
+		for _, rec := range report.collected() {
+			report.AD.AddRecords(rec)
+		}
+		report.AD.Run()
+		report.add(report.AD.Names(), report.AD.Addresses())
+	}
+	if opts.Siblings {
+		siblings, err := NewSiblingAudit(domain, ns, opts.Threads, splitList(opts.Suffixes))
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		report.Siblings = siblings
+		report.Siblings.Run()
+	}
+	if opts.DNSSD {
+		report.DNSSD = NewDNSSDBrowser(domain, ns)
+		report.DNSSD.Run()
+		report.add(report.DNSSD.Names(), report.DNSSD.Addresses())
+	}
+	if opts.Brute != "" {
+		words := make([]string, 0)
+		utils.AppendFileContentsOrString(opts.Brute, &words)
+		engine := NewEngine(ns, opts.Sockets, opts.Rate)
+		hits, err := Brute(domain, words, engine)
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		report.Brute, report.BruteStats = hits, engine.Stats()
+		report.add(hits.Names(), hits.Addresses())
+		report.Graph.AddInventory(hits)
+	}
+	if opts.Expand {
+		report.Inventory = NewExpander(domain, ns, opts.Threads).Expand(report.Discovered)
+		report.add(report.Inventory.Names(), report.Inventory.Addresses())
+		report.Graph.AddInventory(report.Inventory)
+	}
+	if opts.Permute {
+		report.Permutations = NewPermuter(domain, ns, opts.Threads, opts.Rounds, splitList(opts.Wordlist)).Run(report.Discovered)
+		report.add(report.Permutations.Names(), report.Permutations.Addresses())
+		report.Graph.AddInventory(report.Permutations)
+	}
+	if opts.Certs {
+		ports, err := parsePorts(opts.CertPorts)
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		report.Certs = NewCertHarvester(domain, opts.Threads, opts.Time.ToTime(), ports)
+		report.Certs.Harvest(report.Addresses)
+		fresh := slices.DeleteFunc(report.Certs.InScope(), func(name string) bool {
+			return slices.Contains(report.Discovered, name)
+		})
+		report.CertHosts = NewExpander(domain, ns, opts.Threads).Expand(fresh)
+		report.CertHosts.Drop(report.Discovered)
+		report.add(report.CertHosts.Names(), report.CertHosts.Addresses())
+		report.Graph.AddInventory(report.CertHosts)
+	}
+	if opts.ECS {
+		probe, err := NewECSProbe(ns, opts.Threads, splitList(opts.ECSPrefixes))
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		sort.Strings(report.Discovered)
+		probe.Probe(slices.Compact(report.Discovered))
+		report.ECS = probe
+	}
+	if opts.LB {
+		probe := NewLoadBalanceProbe(ns, opts.Threads, opts.LBRounds, opts.LBInterval.ToTime(), splitList(opts.Resolvers))
+		probe.Verbose = opts.Verbose
+		sort.Strings(report.Discovered)
+		probe.Probe(slices.Compact(report.Discovered))
+		report.LoadBalance = probe
+		MergeAddresses(report.Addresses, probe.Addresses())
+	}
+	// Passive, these only read what the stages above collected
+	report.Leaks = NewLeakAudit(domain)
+	report.Providers = NewProviderAnalyzer(domain)
+	report.Mail = NewMailHarvester(domain)
+	for _, rec := range report.collected() {
+		report.Leaks.AddRecords(rec)
+		report.Providers.AddRecords(rec)
+		report.Mail.AddRecords(rec)
+	}
+	for _, inv := range []*Inventory{report.Brute, report.Inventory, report.Permutations, report.CertHosts} {
+		if inv != nil {
+			report.Providers.AddInventory(inv)
+		}
+	}
+	report.Leaks.AddAddresses(report.Addresses)
+	if enricher != nil {
+		report.Enrichment = enricher.Enrich(report.Addresses)
+		report.Graph.AddEnrichment(report.Enrichment)
+	}
+	if graphFile != "" {
+		if err := report.Graph.Export(graphFile); err != nil {
+			report.Err = err
+			return report
+		}
+	}
+	if emailFile != "" {
+		if _, err := report.Mail.Save(emailFile); err != nil {
+			report.Err = err
+			return report
+		}
+		report.EmailFile = emailFile
+	}
+	if opts.Snapshot != "" {
+		file, err := NewSnapshot(report, opts).Save(opts.Snapshot)
+		if err != nil {
+			report.Err = err
+			return report
+		}
+		report.SnapshotFile = file
+	}
+	return report
+}
+
+func (d *DomainReport) add(names []string, addresses map[string][]string) {
+	d.Discovered = append(d.Discovered, names...)
+	MergeAddresses(d.Addresses, addresses)
+}
+
+// collected returns the record check results followed by every zone transfer.
+func (d *DomainReport) collected() []*Records {
+	records := []*Records{d.Records}
+	if d.AXFR != nil {
+		d.AXFR.mu.Lock()
+		records = append(records, slices.Collect(maps.Values(d.AXFR.transfers))...)
+		d.AXFR.mu.Unlock()
+	}
+	return records
+}
+
+func (d *DomainReport) Print() {
+	color.Blue("[ Record Check Results ]")
+	d.Records.Print()
+	if d.AXFR != nil {
+		color.Blue("[ Zone Transfer Results ]")
+		d.AXFR.printTransfers()
+	}
+	if d.DNSSEC != nil {
+		d.DNSSEC.Print()
+	}
+	if d.Delegation != nil {
+		d.Delegation.Print()
+	}
+	if d.Dangling != nil {
+		d.Dangling.Print()
+	}
+	if d.AD != nil {
+		d.AD.Print()
+	}
+	if d.DNSSD != nil {
+		d.DNSSD.Print()
+	}
+	if d.Siblings != nil {
+		d.Siblings.Print()
+	}
+	if d.Brute != nil {
+		color.Blue("[ Brute Force Results ]")
+		fmt.Printf("  %s\n", d.BruteStats)
+		d.Brute.Print()
+	}
+	if d.Inventory != nil {
+		color.Blue("[ Host Inventory ]")
+		d.Inventory.Print()
+	}
+	if d.Permutations != nil {
+		color.Blue("[ Permutation Results ]")
+		d.Permutations.Print()
+	}
+	if d.Certs != nil {
+		color.Blue("[ Certificate Names ]")
+		d.Certs.Print()
+		color.Blue("[ Certificate Hosts ]")
+		d.CertHosts.Print()
+	}
+	if d.ECS != nil {
+		color.Blue("[ EDNS Client Subnet Results ]")
+		d.ECS.Print()
+	}
+	if d.LoadBalance != nil {
+		d.LoadBalance.Print()
+	}
+	// The passive stages always run, only print them when they found something
+	if d.Leaks != nil && d.Leaks.Findings.Len() > 0 {
+		d.Leaks.Print()
+	}
+	if d.Providers != nil && len(d.Providers.Matches) > 0 {
+		d.Providers.Print()
+	}
+	if d.Mail != nil && len(d.Mail.Mailboxes) > 0 {
+		d.Mail.Print()
+	}
+	if d.Enrichment != nil {
+		color.Blue("[ Address Enrichment ]")
+		PrintEnrichment(d.Enrichment)
+	}
+	if d.GraphFile != "" && d.Err == nil {
+		color.Blue("[ Graph ]")
+		fmt.Printf("  |_____%s (%d nodes, %d edges)\n\n", d.GraphFile, len(d.Graph.Nodes), len(d.Graph.Edges))
+	}
+	if d.EmailFile != "" {
+		color.Blue("[ Email File ]")
+		fmt.Printf("  |_____%s (genum smtp -U %s)\n\n", d.EmailFile, d.EmailFile)
+	}
+	if d.SnapshotFile != "" {
+		color.Blue("[ Snapshot ]")
+		fmt.Printf("  |_____%s\n\n", d.SnapshotFile)
+	}
+	color.Blue("[ Summary ]")
+	lines := []string{
+		fmt.Sprintf("Names: %d | Addresses: %d", len(d.Discovered), len(d.Addresses)),
+		"AXFR: " + d.axfrStatus(),
+		"Wildcard: " + d.wildcardStatus(),
+		"DNSSEC: " + d.dnssecStatus(),
+		"Delegation: " + d.delegationStatus(),
+		"NS Takeover: " + d.danglingStatus(),
+		"AD: " + d.adStatus(),
+		"DNS-SD: " + d.dnssdStatus(),
+		"Siblings: " + d.siblingStatus(),
+		"Leakage: " + d.leakStatus(),
+		"Third-Party Services: " + d.providerStatus(),
+		"Emails: " + d.mailStatus(),
+		"Load Balancing: " + d.lbStatus(),
+		"Took: " + d.Took.String(),
+	}
+	if d.Err != nil {
+		lines = append(lines, color.RedString("Error: %v", d.Err))
+	}
+	for i, line := range lines {
+		if i == len(lines)-1 {
+			fmt.Printf("  |_____%s\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+}
+
+func (d *DomainReport) axfrStatus() string {
+	if d.AXFR == nil {
+		return "not attempted"
+	}
+	if allowed := d.AXFR.Allowed(); len(allowed) > 0 {
+		return fmt.Sprintf("allowed (%s)", strings.Join(allowed, ", "))
+	}
+	return "refused"
+}
+
+func (d *DomainReport) wildcardStatus() string {
+	if len(d.Wildcard) == 0 {
+		return "no"
+	}
+	return fmt.Sprintf("yes (%s)", strings.Join(d.Wildcard, ", "))
+}
+
+func (d *DomainReport) dnssecStatus() string {
+	if d.DNSSEC == nil {
+		return "not checked"
+	}
+	return d.DNSSEC.Status()
+}
+
+func (d *DomainReport) delegationStatus() string {
+	if d.Delegation == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d findings", d.Delegation.Findings.Len())
+}
+
+func (d *DomainReport) danglingStatus() string {
+	if d.Dangling == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d findings", d.Dangling.Findings.Len())
+}
+
+func (d *DomainReport) adStatus() string {
+	if d.AD == nil {
+		return "not checked"
+	}
+	dcs, answered := d.AD.controllers(), 0
+	for _, dc := range dcs {
+		if dc.Netlogon != nil {
+			answered++
+		}
+	}
+	return fmt.Sprintf("%d domain controllers, %d answered CLDAP", len(dcs), answered)
+}
+
+func (d *DomainReport) dnssdStatus() string {
+	if d.DNSSD == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d instances of %d service types", len(d.DNSSD.Instances), len(d.DNSSD.Types))
+}
+
+func (d *DomainReport) siblingStatus() string {
+	if d.Siblings == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d exist, %d likely same owner", len(d.Siblings.Siblings), len(d.Siblings.Candidates()))
+}
+
+func (d *DomainReport) leakStatus() string {
+	if d.Leaks == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d findings", d.Leaks.Findings.Len())
+}
+
+func (d *DomainReport) providerStatus() string {
+	if d.Providers == nil {
+		return "not checked"
+	}
+	names := make([]string, 0)
+	for _, match := range d.Providers.Providers() {
+		names = append(names, match.Provider)
+	}
+	sort.Strings(names)
+	return orDash(strings.Join(slices.Compact(names), ", "))
+}
+
+func (d *DomainReport) mailStatus() string {
+	if d.Mail == nil {
+		return "not checked"
+	}
+	if len(d.Mail.Mailboxes) == 0 {
+		return "none"
+	}
+	status := fmt.Sprintf("%d addresses", len(d.Mail.Mailboxes))
+	if format, _, _ := d.Mail.Format(); format != "" {
+		status += " | format " + format
+	}
+	return status
+}
+
+func (d *DomainReport) lbStatus() string {
+	if d.LoadBalance == nil {
+		return "not checked"
+	}
+	return fmt.Sprintf("%d round-robin, %d %s, %d static", d.LoadBalance.Count(LB_ROUND_ROBIN), d.LoadBalance.Count(LB_GSLB), LB_GSLB, d.LoadBalance.Count(LB_STATIC))
+}
+
+// runBatch processes domains with a bounded number of domain workers and
+// prints every report as soon as its domain finishes.
+func runBatch(opts *DNS_Options, domains []string, recordTypes []uint16, enricher *Enricher) []*DomainReport {
+	workers := min(max(opts.DomainWorkers, 1), len(domains))
+	tasks := make(chan int, len(domains))
+	done := make(chan int, len(domains))
+	reports := make([]*DomainReport, len(domains))
+	var wg sync.WaitGroup
+
+	for i := range domains {
+		tasks <- i
+	}
+	close(tasks)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				graphFile := opts.Graph
+				if graphFile != "" && len(domains) > 1 {
+					graphFile = domainFile(graphFile, domains[i])
+				}
+				emailFile := opts.Emails
+				if emailFile != "" && len(domains) > 1 {
+					emailFile = domainFile(emailFile, domains[i])
+				}
+				reports[i] = runDomain(opts, domains[i], recordTypes, enricher, graphFile, emailFile)
+				done <- i
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for i := range done {
+		fmt.Printf(DNS_DOMAIN_STRING, reports[i].Domain)
+		reports[i].Print()
+	}
+	return reports
+}
+
+// PrintRollup condenses a batch into one line per domain.
+func PrintRollup(reports []*DomainReport) {
+	var axfr, wildcard, secure, failed int
+	candidates := make([]string, 0)
+	color.Blue("[ Batch Roll-up ]")
+	for _, report := range reports {
+		line := fmt.Sprintf("%s\tAXFR: %s\tWildcard: %s\tDNSSEC: %s\tNames: %d",
+			report.Domain, report.axfrStatus(), report.wildcardStatus(), report.dnssecStatus(), len(report.Discovered))
+		switch {
+		case report.Err != nil:
+			failed++
+			color.Red("  | \t%s\tError: %v", line, report.Err)
+		case report.AXFR != nil && len(report.AXFR.Allowed()) > 0:
+			color.Yellow("  | \t%s", line)
+		default:
+			fmt.Printf("  | \t%s\n", line)
+		}
+		if report.AXFR != nil && len(report.AXFR.Allowed()) > 0 {
+			axfr++
+		}
+		if len(report.Wildcard) > 0 {
+			wildcard++
+		}
+		if report.DNSSEC != nil && report.DNSSEC.Status() == "secure" {
+			secure++
+		}
+		if report.Siblings != nil {
+			for _, candidate := range report.Siblings.Candidates() {
+				candidates = append(candidates, fmt.Sprintf("%s\tsibling of %s", candidate, report.Domain))
+			}
+		}
+	}
+	fmt.Printf("  |_____Domains: %d | AXFR allowed: %d | Wildcards: %d | DNSSEC secure: %d | Errors: %d | Sibling candidates: %d\n",
+		len(reports), axfr, wildcard, secure, failed, len(candidates))
+	if len(candidates) > 0 {
+		fmt.Println()
+		color.Blue("[ Scope Candidates ]")
+		for i, candidate := range candidates {
+			if i == len(candidates)-1 {
+				fmt.Printf("  |_____%s\n", candidate)
+				break
+			}
+			fmt.Printf("  | \t%s\n", candidate)
+		}
+	}
+}
+
+// domainFile inserts the domain before the extension so batch outputs do not overwrite each other.
+func domainFile(file, domain string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + strings.TrimSuffix(strings.ToLower(domain), ".") + ext
+}
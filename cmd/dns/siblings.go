@@ -0,0 +1,197 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+)
+
+// Public suffixes and ccTLDs the base label is tried under unless --suffixes is given
+var siblingSuffixes = []string{
+	// Generic
+	"com", "net", "org", "info", "biz", "co", "io", "ai", "app", "dev", "cloud", "tech", "online", "site",
+	"store", "shop", "xyz", "me", "tv", "cc", "ws", "mobi", "pro", "name", "group", "global", "inc", "ltd", "llc",
+	// Country codes
+	"us", "ca", "mx", "br", "ar", "cl", "uk", "ie", "de", "at", "ch", "fr", "be", "nl", "lu", "it", "es", "pt",
+	"dk", "se", "no", "fi", "is", "pl", "cz", "sk", "hu", "ro", "bg", "gr", "tr", "ru", "ua", "ee", "lv", "lt",
+	"il", "ae", "sa", "za", "ng", "ke", "eg", "in", "pk", "cn", "hk", "tw", "jp", "kr", "sg", "my", "th", "vn",
+	"id", "ph", "au", "nz", "eu", "asia",
+	// Second level registries
+	"co.uk", "org.uk", "com.au", "net.au", "co.nz", "com.br", "com.mx", "com.ar", "co.za", "co.jp", "co.kr",
+	"co.in", "com.cn", "com.hk", "com.tw", "com.sg", "com.my", "com.tr", "com.ua", "co.il",
+}
+
+// Mail and hosting hosts serving many unrelated customers, sharing them says
+// nothing about ownership. Managed DNS providers are matched by dnsProviders.
+var sharedInfrastructure = []string{
+	".amazon.com.",
+	".aspmx.l.google.com.",
+	".googlemail.com.",
+	".secureserver.net.",
+	".zoho.com.",
+	".zoho.eu.",
+	".yandex.net.",
+	".mailgun.org.",
+	".icloud.com.",
+	".ovh.net.",
+	".hostgator.com.",
+	".bluehost.com.",
+}
+
+// sharedHost reports hosts used by unrelated customers. Route 53 hands every
+// zone its own delegation set, so matching awsdns names still count.
+func sharedHost(host string) bool {
+	host = "." + dns.Fqdn(strings.ToLower(host))
+	for _, pattern := range sharedInfrastructure {
+		if strings.Contains(host, pattern) {
+			return true
+		}
+	}
+	return matchProvider(host) != "" && !strings.Contains(host, ".awsdns-")
+}
+
+type Sibling struct {
+	*Lookalike
+	RName  string
+	Shared []string
+}
+
+// SameOwner reports whether the sibling shares NS, MX or SOA RNAME with the primary domain.
+func (s *Sibling) SameOwner() bool {
+	return len(s.Shared) > 0
+}
+
+// SiblingAudit tries the base label of a domain under other public suffixes
+// and compares the ones that exist with the primary domain.
+type SiblingAudit struct {
+	Domain     string
+	Nameserver string
+	NS         []string
+	MX         []string
+	RName      string
+	Siblings   []*Sibling
+	twister    *Twister
+}
+
+func NewSiblingAudit(domain, nameserver string, threads int, suffixes []string) (*SiblingAudit, error) {
+	if len(suffixes) == 0 {
+		suffixes = siblingSuffixes
+	}
+	twister, err := NewTwister(domain, nameserver, threads, suffixes)
+	if err != nil {
+		return nil, err
+	}
+	return &SiblingAudit{
+		Domain:     twister.Domain,
+		Nameserver: nameserver,
+		Siblings:   make([]*Sibling, 0),
+		twister:    twister,
+	}, nil
+}
+
+func (s *SiblingAudit) Run() {
+	primary := &Lookalike{Domain: s.Domain}
+	s.twister.resolve(primary)
+	s.NS, s.MX, s.RName = primary.NS, primary.MX, s.rname(s.Domain)
+
+	s.twister.Run(s.twister.Generate([]string{FUZZ_TLD}))
+	for _, lookalike := range s.twister.Results {
+		if !lookalike.Registered() {
+			continue
+		}
+		sibling := &Sibling{Lookalike: lookalike, RName: s.rname(lookalike.Domain), Shared: make([]string, 0)}
+		if hosts := s.shared(s.NS, sibling.NS); len(hosts) > 0 {
+			sibling.Shared = append(sibling.Shared, "NS "+strings.Join(hosts, ", "))
+		}
+		if hosts := s.shared(s.MX, sibling.MX); len(hosts) > 0 {
+			sibling.Shared = append(sibling.Shared, "MX "+strings.Join(hosts, ", "))
+		}
+		if sibling.RName != "" && sibling.RName == s.RName && !sharedHost(s.RName) {
+			sibling.Shared = append(sibling.Shared, "RNAME "+s.RName)
+		}
+		s.Siblings = append(s.Siblings, sibling)
+	}
+}
+
+// rname returns the SOA RNAME of a zone apex.
+func (s *SiblingAudit) rname(domain string) string {
+	rrs, err := lookup(domain, dns.TypeSOA, s.Nameserver)
+	if err != nil {
+		return ""
+	}
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, domain) {
+			return strings.ToLower(soa.Mbox)
+		}
+	}
+	return ""
+}
+
+// shared returns the sibling hosts that the primary uses too or that live under the primary domain.
+func (s *SiblingAudit) shared(primary, sibling []string) []string {
+	hosts := make([]string, 0)
+	for _, host := range sibling {
+		if dns.IsSubDomain(s.Domain, host) || (slices.Contains(primary, host) && !sharedHost(host)) {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return slices.Compact(hosts)
+}
+
+// Candidates returns the siblings that are likely run by the same owner, for scoping.
+func (s *SiblingAudit) Candidates() []string {
+	candidates := make([]string, 0)
+	for _, sibling := range s.Siblings {
+		if sibling.SameOwner() {
+			candidates = append(candidates, sibling.Domain)
+		}
+	}
+	return candidates
+}
+
+func (s *SiblingAudit) Print() {
+	color.Blue("[ Sibling Domains ]")
+	fmt.Printf("  | \tBase: %s | Suffixes tried: %d\n", s.twister.Label, len(s.twister.Results))
+	fmt.Printf("  |_____Primary NS: %s | MX: %s | RNAME: %s\n\n", orDash(strings.Join(s.NS, ", ")), orDash(strings.Join(s.MX, ", ")), orDash(s.RName))
+	for _, sibling := range s.Siblings {
+		header := fmt.Sprintf("  [ %s ]", sibling.Domain)
+		if sibling.SameOwner() {
+			color.Yellow(header + " likely same owner")
+		} else {
+			fmt.Println(header)
+		}
+		lines := []string{
+			"NS: " + orDash(strings.Join(sibling.NS, ", ")),
+			"MX: " + orDash(strings.Join(sibling.MX, ", ")),
+			"RNAME: " + orDash(sibling.RName),
+		}
+		if sibling.SameOwner() {
+			lines = append(lines, "Shared: "+strings.Join(sibling.Shared, " | "))
+		}
+		for i, line := range lines {
+			if i == len(lines)-1 {
+				fmt.Printf("  |_____%s\n\n", line)
+				break
+			}
+			fmt.Printf("  | \t%s\n", line)
+		}
+	}
+	color.Blue("[ Scope Candidates ]")
+	candidates := s.Candidates()
+	if len(candidates) == 0 {
+		fmt.Printf("  |_____None\n\n")
+		return
+	}
+	for i, candidate := range candidates {
+		if i == len(candidates)-1 {
+			fmt.Printf("  |_____%s\n\n", candidate)
+			break
+		}
+		fmt.Printf("  | \t%s\n", candidate)
+	}
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const SNAPSHOT_TIME_FORMAT = "20060102T150405"
+
+// Snapshot is the on disk form of one domain's results, records are kept in
+// zone file presentation format so they can be parsed back with dns.NewRR.
+// Siblings keeps the likely same owner domains found by --siblings for scoping.
+type Snapshot struct {
+	Domain     string              `json:"domain"`
+	Nameserver string              `json:"nameserver"`
+	Time       time.Time           `json:"time"`
+	Records    []string            `json:"records"`
+	Transfers  map[string][]string `json:"transfers"`
+	Hosts      []string            `json:"hosts"`
+	Stages     *SnapshotStages     `json:"stages,omitempty"`
+	Siblings   []string            `json:"siblings,omitempty"`
+}
+
+// SnapshotStages keeps the options that add hosts beyond the record check so
+// a live diff can run the same stages again.
+type SnapshotStages struct {
+	Types     string `json:"types"`
+	Expand    bool   `json:"expand,omitempty"`
+	Permute   bool   `json:"permute,omitempty"`
+	Wordlist  string `json:"wordlist,omitempty"`
+	Rounds    int    `json:"rounds,omitempty"`
+	Brute     string `json:"brute,omitempty"`
+	Certs     bool   `json:"certs,omitempty"`
+	CertPorts string `json:"cert_ports,omitempty"`
+	AD        bool   `json:"ad,omitempty"`
+	DNSSD     bool   `json:"dnssd,omitempty"`
+}
+
+func newSnapshotStages(opts *DNS_Options) *SnapshotStages {
+	return &SnapshotStages{
+		Types:     opts.Type,
+		Expand:    opts.Expand,
+		Permute:   opts.Permute,
+		Wordlist:  opts.Wordlist,
+		Rounds:    opts.Rounds,
+		Brute:     opts.Brute,
+		Certs:     opts.Certs,
+		CertPorts: opts.CertPorts,
+		AD:        opts.AD,
+		DNSSD:     opts.DNSSD,
+	}
+}
+
+// apply enables the recorded stages on opts.
+func (s *SnapshotStages) apply(opts *DNS_Options) {
+	opts.Expand, opts.Permute, opts.Wordlist, opts.Rounds = s.Expand, s.Permute, s.Wordlist, s.Rounds
+	opts.Brute, opts.Certs, opts.CertPorts = s.Brute, s.Certs, s.CertPorts
+	opts.AD, opts.DNSSD = s.AD, s.DNSSD
+}
+
+// sameStages reports whether two snapshots discovered hosts the same way.
+func sameStages(a, b *SnapshotStages) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+func NewSnapshot(report *DomainReport, opts *DNS_Options) *Snapshot {
+	snap := &Snapshot{
+		Domain:     report.Domain,
+		Nameserver: opts.Nameserver,
+		Stages:     newSnapshotStages(opts),
+		Time:       time.Now().UTC(),
+		Records:    rrStrings(report.Records),
+		Transfers:  make(map[string][]string),
+		Hosts:      slices.Clone(report.Discovered),
+	}
+	sort.Strings(snap.Hosts)
+	snap.Hosts = slices.Compact(snap.Hosts)
+	if report.Siblings != nil {
+		snap.Siblings = report.Siblings.Candidates()
+	}
+	if report.AXFR != nil {
+		report.AXFR.mu.Lock()
+		for key, rec := range report.AXFR.transfers {
+			snap.Transfers[key] = rrStrings(rec)
+		}
+		report.AXFR.mu.Unlock()
+	}
+	return snap
+}
+
+func rrStrings(r *Records) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, 0)
+	for _, rrs := range r.Data {
+		for _, rr := range rrs {
+			out = append(out, rr.String())
+		}
+	}
+	sort.Strings(out)
+	return slices.Compact(out)
+}
+
+// Save writes the snapshot to dir as <domain>_<time>.json and returns the file name.
+func (s *Snapshot) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("Snapshot Save Error: %v", err)
+	}
+	name := fmt.Sprintf("%s_%s.json", strings.TrimSuffix(s.Domain, "."), s.Time.Format(SNAPSHOT_TIME_FORMAT))
+	file := filepath.Join(dir, name)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Snapshot Save Error: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return "", fmt.Errorf("Snapshot Save Error: %v", err)
+	}
+	return file, nil
+}
+
+func LoadSnapshot(file string) (*Snapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Snapshot Load Error: %v", err)
+	}
+	snap := new(Snapshot)
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("Snapshot Load Error: %s: %v", file, err)
+	}
+	if snap.Transfers == nil {
+		snap.Transfers = make(map[string][]string)
+	}
+	return snap, nil
+}
+
+// RRs parses every record of the snapshot, queried and transferred alike.
+func (s *Snapshot) RRs() []dns.RR {
+	all := slices.Clone(s.Records)
+	for _, recs := range s.Transfers {
+		all = append(all, recs...)
+	}
+	rrs := make([]dns.RR, 0, len(all))
+	for _, text := range all {
+		if rr, err := dns.NewRR(text); err == nil && rr != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
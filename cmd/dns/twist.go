@@ -0,0 +1,438 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+const CATEGORY_LOOKALIKE = "Lookalike"
+
+const (
+	FUZZ_HOMOGLYPH     = "homoglyph"
+	FUZZ_BITFLIP       = "bitflip"
+	FUZZ_TRANSPOSITION = "transposition"
+	FUZZ_OMISSION      = "omission"
+	FUZZ_TLD           = "tld"
+	FUZZ_HYPHENATION   = "hyphenation"
+)
+
+var twistFuzzers = []string{
+	FUZZ_HOMOGLYPH,
+	FUZZ_BITFLIP,
+	FUZZ_TRANSPOSITION,
+	FUZZ_OMISSION,
+	FUZZ_TLD,
+	FUZZ_HYPHENATION,
+}
+
+// Suffixes tried by the TLD swap fuzzer unless --tlds is given
+var twistTLDs = []string{
+	"com", "net", "org", "info", "biz", "co", "io", "us", "uk", "co.uk", "de", "eu",
+	"ru", "cn", "xyz", "online", "site", "app", "dev", "me", "cc", "top",
+}
+
+// ASCII and Unicode lookalikes per letter, Unicode results are tried as punycode
+var homoglyphs = map[rune][]string{
+	'a': {"4", "à", "á", "â", "ã", "ä", "å", "ɑ", "а"},
+	'b': {"d", "lb", "ib", "ʙ", "ƅ"},
+	'c': {"e", "ç", "ć", "с", "ϲ"},
+	'd': {"b", "cl", "dl", "ԁ", "ɗ"},
+	'e': {"3", "c", "é", "è", "ê", "ë", "е", "ė"},
+	'f': {"ƒ"},
+	'g': {"q", "9", "ɢ", "ġ", "ɡ"},
+	'h': {"lh", "ih", "һ"},
+	'i': {"1", "l", "í", "ì", "ï", "ı", "і"},
+	'j': {"ј", "ʝ"},
+	'k': {"lc", "ĸ", "κ"},
+	'l': {"1", "i", "ɫ", "ł", "ӏ"},
+	'm': {"n", "rn", "nn", "ṃ", "м"},
+	'n': {"m", "r", "ń", "ñ", "ո"},
+	'o': {"0", "ο", "о", "ö", "ó", "ò", "ø"},
+	'p': {"ρ", "р"},
+	'q': {"g", "ԛ"},
+	'r': {"ʀ", "г"},
+	's': {"5", "ѕ", "ś", "ş"},
+	't': {"ţ", "ť", "τ"},
+	'u': {"μ", "υ", "ü", "ú", "ù"},
+	'v': {"ν", "ѵ"},
+	'w': {"vv", "ѡ", "ŵ"},
+	'x': {"х", "ҳ"},
+	'y': {"ү", "ý", "ÿ"},
+	'z': {"2", "ʐ", "ż", "ź"},
+}
+
+const TWIST_START_STRING = `
+[LOOKALIKE DOMAINS]
+ Domain: %s
+ Nameserver: %s
+ Fuzzers: %s
+ Time Start: %s
+`
+
+var TwistCmd = &cobra.Command{
+	Use:   "twist",
+	Short: "Typosquat and lookalike domain discovery",
+	Long: `
+[LOOKALIKE DOMAINS]
+	[-- REQUIRED --]
+	-d <Domain to generate lookalikes of>
+
+	[-- OPTIONAL --]
+	-n <Nameserver to resolve DNS queries>
+	-T <Thread Count>
+	-f <Fuzzers: homoglyph, bitflip, transposition, omission, tld, hyphenation>
+	--tlds <Suffix or file of suffixes for the tld fuzzer>
+	-v <Print unregistered lookalikes too>
+
+	[-- EXAMPLES --]
+	genum dns twist -d example.com
+	genum dns twist -d example.com -f homoglyph,tld --tlds com,net,shop
+`,
+	PreRunE: validateTwist,
+	RunE:    executeTwist,
+}
+
+type Twist_Options struct {
+	utils.Options
+	Domain     string
+	Nameserver string
+	Threads    int
+	Fuzzers    string
+	TLDs       string
+	Verbose    bool
+}
+
+func init() {
+	TwistCmd.Flags().StringP("domain", "d", "", "domain to generate lookalikes of")
+	TwistCmd.Flags().StringP("nameserver", "n", DEFAULT_NAME_SERVER, "nameserver to resolve queries")
+	TwistCmd.Flags().IntP("threads", "T", DEFAULT_THREAD_COUNT, "Thread Count: Default: 10")
+	TwistCmd.Flags().StringP("fuzzers", "f", strings.Join(twistFuzzers, ","), "comma separated fuzzers to run")
+	TwistCmd.Flags().String("tlds", "", "suffix, comma separated suffixes or file of suffixes for the tld fuzzer")
+	TwistCmd.Flags().BoolP("verbose", "v", false, "Verbose output, prints unregistered lookalikes too")
+	DNSCmd.AddCommand(TwistCmd)
+}
+
+func validateTwist(cmd *cobra.Command, args []string) error {
+	var options = new(Twist_Options)
+	err := options.AddRequired(cmd,
+		"domain", &options.Domain,
+	)
+	if err != nil {
+		return err
+	}
+	err = options.Add(cmd,
+		"nameserver", &options.Nameserver,
+		"threads", &options.Threads,
+		"fuzzers", &options.Fuzzers,
+		"tlds", &options.TLDs,
+		"verbose", &options.Verbose,
+	)
+	if err != nil {
+		return err
+	}
+	for _, fuzzer := range splitList(options.Fuzzers) {
+		if !slices.Contains(twistFuzzers, strings.ToLower(fuzzer)) {
+			return fmt.Errorf("Invalid Fuzzer: %s", fuzzer)
+		}
+	}
+	cmd.SetContext(context.WithValue(cmd.Context(), Key{}, options))
+	return nil
+}
+
+func executeTwist(cmd *cobra.Command, args []string) error {
+	validatedArgs := cmd.Context().Value(Key{})
+	if validatedArgs == nil {
+		return fmt.Errorf("[Command Line Options Error]")
+	}
+	opts, ok := validatedArgs.(*Twist_Options)
+	if !ok {
+		return fmt.Errorf("Invalid Type: %T", validatedArgs)
+	}
+	tlds := twistTLDs
+	if opts.TLDs != "" {
+		tlds = splitList(opts.TLDs)
+	}
+	twister, err := NewTwister(opts.Domain, opts.Nameserver, opts.Threads, tlds)
+	if err != nil {
+		return err
+	}
+	fuzzers := make([]string, 0)
+	for _, fuzzer := range splitList(opts.Fuzzers) {
+		fuzzers = append(fuzzers, strings.ToLower(fuzzer))
+	}
+
+	start_time := time.Now()
+	fmt.Printf(TWIST_START_STRING, twister.Domain, opts.Nameserver, strings.Join(fuzzers, ","), start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[PROGRESS]---------------------")
+
+	twister.Run(twister.Generate(fuzzers))
+	twister.Flag()
+	twister.Print(opts.Verbose)
+
+	end_time := time.Now()
+	fmt.Printf(DNS_END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	return nil
+}
+
+type Lookalike struct {
+	Domain  string
+	Unicode string
+	Fuzzer  string
+	Status  string
+	A       []string
+	MX      []string
+	NS      []string
+}
+
+func (l *Lookalike) Registered() bool {
+	return l.Status == dns.RcodeToString[dns.RcodeSuccess]
+}
+
+// Twister generates lookalikes of the registrable part of a domain and
+// checks which of them exist and can handle mail.
+type Twister struct {
+	Domain     string
+	Label      string
+	Suffix     string
+	Nameserver string
+	Threads    int
+	TLDs       []string
+	Results    []*Lookalike
+	Findings   *Findings
+	mu         sync.Mutex
+}
+
+func NewTwister(domain, nameserver string, threads int, tlds []string) (*Twister, error) {
+	if threads <= 0 {
+		threads = DEFAULT_THREAD_COUNT
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(strings.TrimSuffix(domain, ".")))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Domain: %v", err)
+	}
+	suffix, _ := publicsuffix.PublicSuffix(registrable)
+	label := strings.TrimSuffix(registrable, "."+suffix)
+	if unicode, err := idna.ToUnicode(label); err == nil {
+		label = unicode
+	}
+	return &Twister{
+		Domain:     dns.Fqdn(registrable),
+		Label:      label,
+		Suffix:     suffix,
+		Nameserver: nameserver,
+		Threads:    threads,
+		TLDs:       tlds,
+		Results:    make([]*Lookalike, 0),
+		Findings:   NewFindings(),
+	}, nil
+}
+
+// Generate returns every distinct lookalike the given fuzzers produce.
+func (t *Twister) Generate(fuzzers []string) []*Lookalike {
+	seen := map[string]bool{t.Domain: true}
+	lookalikes := make([]*Lookalike, 0)
+	add := func(fuzzer, label, suffix string) {
+		unicode := label + "." + suffix
+		ascii, err := idna.Lookup.ToASCII(unicode)
+		if err != nil || !validLookalike(ascii) {
+			return
+		}
+		ascii = dns.Fqdn(ascii)
+		if seen[ascii] {
+			return
+		}
+		seen[ascii] = true
+		lookalike := &Lookalike{Domain: ascii, Fuzzer: fuzzer}
+		if ascii != dns.Fqdn(unicode) {
+			lookalike.Unicode = unicode
+		}
+		lookalikes = append(lookalikes, lookalike)
+	}
+
+	runes := []rune(t.Label)
+	for _, fuzzer := range fuzzers {
+		switch fuzzer {
+		case FUZZ_HOMOGLYPH:
+			for i, r := range runes {
+				for _, glyph := range homoglyphs[r] {
+					add(fuzzer, string(runes[:i])+glyph+string(runes[i+1:]), t.Suffix)
+				}
+			}
+		case FUZZ_BITFLIP:
+			for i, r := range runes {
+				if r > 0x7f {
+					continue
+				}
+				for bit := 0; bit < 8; bit++ {
+					flipped := r ^ (1 << bit)
+					if (flipped >= 'a' && flipped <= 'z') || (flipped >= '0' && flipped <= '9') || flipped == '-' {
+						add(fuzzer, string(runes[:i])+string(flipped)+string(runes[i+1:]), t.Suffix)
+					}
+				}
+			}
+		case FUZZ_TRANSPOSITION:
+			for i := 0; i < len(runes)-1; i++ {
+				swapped := slices.Clone(runes)
+				swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+				add(fuzzer, string(swapped), t.Suffix)
+			}
+		case FUZZ_OMISSION:
+			for i := range runes {
+				add(fuzzer, string(runes[:i])+string(runes[i+1:]), t.Suffix)
+			}
+		case FUZZ_TLD:
+			for _, tld := range t.TLDs {
+				add(fuzzer, t.Label, strings.Trim(strings.ToLower(tld), "."))
+			}
+		case FUZZ_HYPHENATION:
+			for i := 1; i < len(runes); i++ {
+				add(fuzzer, string(runes[:i])+"-"+string(runes[i:]), t.Suffix)
+			}
+		}
+	}
+	return lookalikes
+}
+
+// validLookalike rejects names with an empty or malformed first label.
+func validLookalike(domain string) bool {
+	label, _, _ := strings.Cut(domain, ".")
+	if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return false
+	}
+	if len(label) > 3 && label[2:4] == "--" && !strings.HasPrefix(label, "xn--") {
+		return false
+	}
+	_, ok := dns.IsDomainName(domain)
+	return ok
+}
+
+func (t *Twister) Run(lookalikes []*Lookalike) {
+	tasks := make(chan *Lookalike, 100)
+	var wg sync.WaitGroup
+
+	go func() {
+		for _, lookalike := range lookalikes {
+			tasks <- lookalike
+		}
+		close(tasks)
+	}()
+	for i := 0; i < t.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lookalike := range tasks {
+				t.resolve(lookalike)
+				t.mu.Lock()
+				t.Results = append(t.Results, lookalike)
+				t.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(t.Results, func(i, j int) bool { return t.Results[i].Domain < t.Results[j].Domain })
+}
+
+// Flag turns every registered lookalike into a finding, mail capable ones rank higher.
+func (t *Twister) Flag() {
+	for _, lookalike := range t.Results {
+		t.flag(lookalike)
+	}
+}
+
+func (t *Twister) flag(l *Lookalike) {
+	if !l.Registered() {
+		return
+	}
+	name := l.Domain
+	if l.Unicode != "" {
+		name = fmt.Sprintf("%s (%s)", l.Domain, l.Unicode)
+	}
+	if len(l.MX) > 0 {
+		t.Findings.Add(SEVERITY_MEDIUM, CATEGORY_LOOKALIKE, name, fmt.Sprintf(
+			"registered %s lookalike of %s with mail servers %s, usable to phish or catch misdirected mail", l.Fuzzer, t.Domain, strings.Join(l.MX, ", ")))
+		return
+	}
+	t.Findings.Add(SEVERITY_LOW, CATEGORY_LOOKALIKE, name, fmt.Sprintf("registered %s lookalike of %s", l.Fuzzer, t.Domain))
+}
+
+// resolve uses the NS answer code to tell registered names apart and then collects A and MX.
+func (t *Twister) resolve(l *Lookalike) {
+	in, err := exchange(l.Domain, dns.TypeNS, t.Nameserver)
+	if err != nil {
+		l.Status = "error"
+		return
+	}
+	l.Status = dns.RcodeToString[in.Rcode]
+	if !l.Registered() {
+		return
+	}
+	for _, rr := range in.Answer {
+		if ns, ok := rr.(*dns.NS); ok {
+			l.NS = append(l.NS, strings.ToLower(ns.Ns))
+		}
+	}
+	if rrs, err := lookup(l.Domain, dns.TypeA, t.Nameserver); err == nil {
+		for _, rr := range rrs {
+			if a, ok := rr.(*dns.A); ok {
+				l.A = append(l.A, a.A.String())
+			}
+		}
+	}
+	if rrs, err := lookup(l.Domain, dns.TypeMX, t.Nameserver); err == nil {
+		for _, rr := range rrs {
+			// A null MX (RFC 7505) explicitly refuses mail
+			if mx, ok := rr.(*dns.MX); ok && mx.Mx != "." {
+				l.MX = append(l.MX, strings.ToLower(mx.Mx))
+			}
+		}
+	}
+}
+
+func (t *Twister) Print(verbose bool) {
+	registered, mail := 0, 0
+	for _, l := range t.Results {
+		if l.Registered() {
+			registered++
+		}
+		if len(l.MX) > 0 {
+			mail++
+		}
+	}
+	color.Blue("[ Lookalike Domains ]")
+	fmt.Printf("  |_____Generated: %d | Registered: %d | With MX: %d\n\n", len(t.Results), registered, mail)
+
+	for _, l := range t.Results {
+		if !l.Registered() && !verbose {
+			continue
+		}
+		header := fmt.Sprintf("  [ %s ] %s", l.Domain, l.Fuzzer)
+		if l.Unicode != "" {
+			header += " " + l.Unicode
+		}
+		if !l.Registered() {
+			fmt.Printf("%s %s\n", header, l.Status)
+			continue
+		}
+		color.Yellow(header)
+		fmt.Printf("  | \tA: %s\n", orDash(strings.Join(l.A, ", ")))
+		fmt.Printf("  | \tMX: %s\n", orDash(strings.Join(l.MX, ", ")))
+		fmt.Printf("  |_____NS: %s\n\n", orDash(strings.Join(l.NS, ", ")))
+	}
+	if verbose {
+		fmt.Println()
+	}
+	color.Blue("[ Lookalike Findings ]")
+	t.Findings.Print()
+}
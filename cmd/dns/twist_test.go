@@ -0,0 +1,143 @@
+package dns
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/idna"
+)
+
+func newTestTwister(t *testing.T, domain string) *Twister {
+	t.Helper()
+	twister, err := NewTwister(domain, "", 1, []string{"net", "org"})
+	if err != nil {
+		t.Fatalf("NewTwister(%q): %v", domain, err)
+	}
+	return twister
+}
+
+func TestNewTwisterSplitsRegistrable(t *testing.T) {
+	tests := []struct {
+		domain string
+		label  string
+		suffix string
+	}{
+		{"example.com", "example", "com"},
+		{"www.example.com.", "example", "com"},
+		{"shop.example.co.uk", "example", "co.uk"},
+		{"xn--bcher-kva.de", "bücher", "de"},
+	}
+	for _, tt := range tests {
+		twister := newTestTwister(t, tt.domain)
+		if twister.Label != tt.label || twister.Suffix != tt.suffix {
+			t.Errorf("%s: label %q suffix %q, want %q %q", tt.domain, twister.Label, twister.Suffix, tt.label, tt.suffix)
+		}
+	}
+	if _, err := NewTwister("com", "", 1, nil); err == nil {
+		t.Error("a bare public suffix was accepted")
+	}
+}
+
+func TestGenerateBitflip(t *testing.T) {
+	twister := newTestTwister(t, "example.com")
+	results := twister.Generate([]string{FUZZ_BITFLIP})
+	if len(results) == 0 {
+		t.Fatal("no bitflip lookalikes")
+	}
+	found := make(map[string]bool)
+	for _, lookalike := range results {
+		found[lookalike.Domain] = true
+		label := strings.TrimSuffix(lookalike.Domain, ".com.")
+		if len(label) != len(twister.Label) {
+			t.Errorf("%s: length changed", lookalike.Domain)
+			continue
+		}
+		diffs := 0
+		for i := range label {
+			if label[i] == twister.Label[i] {
+				continue
+			}
+			diffs++
+			if bits.OnesCount8(label[i]^twister.Label[i]) != 1 {
+				t.Errorf("%s: %q -> %q is not a single bit flip", lookalike.Domain, twister.Label[i], label[i])
+			}
+			if c := label[i]; !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				t.Errorf("%s: flipped into %q, outside the hostname alphabet", lookalike.Domain, c)
+			}
+		}
+		if diffs != 1 {
+			t.Errorf("%s: %d positions differ, want 1", lookalike.Domain, diffs)
+		}
+	}
+	// e (0x65) with bit 0 flipped is d, with bit 4 flipped is u
+	for _, want := range []string{"dxample.com.", "uxample.com.", "exaople.com."} {
+		if !found[want] {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestGenerateHomoglyphRoundTrip(t *testing.T) {
+	twister := newTestTwister(t, "paypal.com")
+	unicode := 0
+	for _, lookalike := range twister.Generate([]string{FUZZ_HOMOGLYPH}) {
+		if lookalike.Unicode == "" {
+			if strings.HasPrefix(lookalike.Domain, "xn--") {
+				t.Errorf("%s is punycode without its Unicode form", lookalike.Domain)
+			}
+			continue
+		}
+		unicode++
+		if !strings.HasPrefix(lookalike.Domain, "xn--") {
+			t.Errorf("%s (%s) is not punycode", lookalike.Domain, lookalike.Unicode)
+		}
+		back, err := idna.ToUnicode(strings.TrimSuffix(lookalike.Domain, "."))
+		if err != nil || back != lookalike.Unicode {
+			t.Errorf("%s decodes to %q (%v), want %q", lookalike.Domain, back, err, lookalike.Unicode)
+		}
+	}
+	if unicode == 0 {
+		t.Error("no Unicode homoglyphs generated")
+	}
+}
+
+func TestGenerateDeduplicates(t *testing.T) {
+	// "google" repeats letters, so omission and transposition produce the same name more than once
+	twister := newTestTwister(t, "google.com")
+	seen := make(map[string]string)
+	for _, lookalike := range twister.Generate(twistFuzzers) {
+		if lookalike.Domain == twister.Domain {
+			t.Errorf("%s fuzzer returned the original domain", lookalike.Fuzzer)
+		}
+		if fuzzer, ok := seen[lookalike.Domain]; ok {
+			t.Errorf("%s generated by %s and %s", lookalike.Domain, fuzzer, lookalike.Fuzzer)
+		}
+		seen[lookalike.Domain] = lookalike.Fuzzer
+	}
+	if seen["gogle.com."] != FUZZ_OMISSION {
+		t.Errorf("gogle.com. fuzzer = %q, want %s", seen["gogle.com."], FUZZ_OMISSION)
+	}
+	if seen["google.net."] != FUZZ_TLD {
+		t.Errorf("google.net. fuzzer = %q, want %s", seen["google.net."], FUZZ_TLD)
+	}
+}
+
+func TestValidLookalike(t *testing.T) {
+	tests := map[string]bool{
+		"example.com.":                    true,
+		"ex-ample.com.":                   true,
+		"xn--exmple-cua.com.":             true,
+		"-example.com.":                   false,
+		"example-.com.":                   false,
+		"exa--mple.com.":                  true,
+		"ab--cd.com.":                     false,
+		".com.":                           false,
+		strings.Repeat("a", 64) + ".com.": false,
+	}
+	for domain, want := range tests {
+		if got := validLookalike(domain); got != want {
+			t.Errorf("validLookalike(%q) = %v, want %v", domain, got, want)
+		}
+	}
+}
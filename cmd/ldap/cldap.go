@@ -0,0 +1,312 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CLDAP_PORT      = 389
+	CLDAP_BUF_SIZE  = 4096
+	NETLOGON_NT_VER = 0x00000006 // NETLOGON_NT_VERSION_5 | NETLOGON_NT_VERSION_5EX
+	// Account control bits matched by the User= filter: normal user accounts
+	NETLOGON_AAC = 0x00000010
+)
+
+// NetLogon response opcodes (MS-ADTS 6.3.1.2)
+const (
+	LOGON_SAM_LOGON_RESPONSE    = 19
+	LOGON_SAM_PAUSE_RESPONSE    = 20
+	LOGON_SAM_USER_UNKNOWN      = 21
+	LOGON_SAM_LOGON_RESPONSE_EX = 23
+	LOGON_SAM_PAUSE_RESPONSE_EX = 24
+	LOGON_SAM_USER_UNKNOWN_EX   = 25
+)
+
+// DS_FLAG bits of a NetLogon response, in the order they are printed
+var dsFlags = []struct {
+	Bit  uint32
+	Name string
+}{
+	{0x00000001, "PDC"},
+	{0x00000004, "GC"},
+	{0x00000008, "LDAP"},
+	{0x00000010, "DS"},
+	{0x00000020, "KDC"},
+	{0x00000040, "TIMESERV"},
+	{0x00000080, "CLOSEST"},
+	{0x00000100, "WRITABLE"},
+	{0x00000200, "GOOD_TIMESERV"},
+	{0x00000400, "NDNC"},
+	{0x00000800, "RODC"},
+	{0x00001000, "FULL_SECRET"},
+	{0x00002000, "WS"},
+	{0x00004000, "DS_8"},
+	{0x00008000, "DS_9"},
+	{0x00010000, "DS_10"},
+	{0x20000000, "DNS_CONTROLLER"},
+	{0x40000000, "DNS_DOMAIN"},
+	{0x80000000, "DNS_FOREST"},
+}
+
+// Netlogon is a decoded NETLOGON_SAM_LOGON_RESPONSE_EX.
+type Netlogon struct {
+	Opcode        uint16
+	Flags         uint32
+	DomainGUID    string
+	Forest        string
+	Domain        string
+	HostName      string
+	NetbiosDomain string
+	NetbiosName   string
+	UserName      string
+	DCSite        string
+	ClientSite    string
+	NtVersion     uint32
+}
+
+func (n *Netlogon) FlagNames() []string {
+	names := make([]string, 0)
+	for _, flag := range dsFlags {
+		if n.Flags&flag.Bit != 0 {
+			names = append(names, flag.Name)
+		}
+	}
+	return names
+}
+
+// UserKnown reports whether the DC answered for an existing account.
+func (n *Netlogon) UserKnown() bool {
+	switch n.Opcode {
+	case LOGON_SAM_LOGON_RESPONSE, LOGON_SAM_LOGON_RESPONSE_EX, LOGON_SAM_PAUSE_RESPONSE, LOGON_SAM_PAUSE_RESPONSE_EX:
+		return true
+	}
+	return false
+}
+
+// NetlogonPing sends a CLDAP NetLogon search to a domain controller. An empty
+// user asks for the DC information only.
+func NetlogonPing(host string, port int, domain, user string, timeout time.Duration) (*Netlogon, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("CLDAP Connection Error: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	id := int(time.Now().UnixNano() & 0x7fffffff)
+	if _, err := conn.Write(netlogonRequest(id, domain, user)); err != nil {
+		return nil, fmt.Errorf("CLDAP Write Error: %v", err)
+	}
+	buf := make([]byte, CLDAP_BUF_SIZE)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("CLDAP Read Error: %v", err)
+	}
+	value, err := netlogonValue(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	return parseNetlogon(value)
+}
+
+// netlogonRequest builds the LDAP SearchRequest for the Netlogon attribute of the rootDSE.
+func netlogonRequest(id int, domain, user string) []byte {
+	ntver := make([]byte, 4)
+	binary.LittleEndian.PutUint32(ntver, NETLOGON_NT_VER)
+	filters := [][]byte{
+		equalityMatch("DnsDomain", []byte(strings.TrimSuffix(domain, "."))),
+		equalityMatch("NtVer", ntver),
+	}
+	if user != "" {
+		aac := make([]byte, 4)
+		binary.LittleEndian.PutUint32(aac, NETLOGON_AAC)
+		filters = append(filters, equalityMatch("User", []byte(user)), equalityMatch("AAC", aac))
+	}
+	search := bytes.Join([][]byte{
+		tlv(0x04, nil),                      // baseObject: rootDSE
+		tlv(0x0a, []byte{0}),                // scope: baseObject
+		tlv(0x0a, []byte{0}),                // derefAliases: never
+		tlv(0x02, []byte{0}),                // sizeLimit
+		tlv(0x02, []byte{0}),                // timeLimit
+		tlv(0x01, []byte{0}),                // typesOnly: false
+		tlv(0xa0, bytes.Join(filters, nil)), // and
+		tlv(0x30, tlv(0x04, []byte("Netlogon"))),
+	}, nil)
+	return tlv(0x30, append(tlv(0x02, berInt(id)), tlv(0x63, search)...))
+}
+
+func equalityMatch(attr string, value []byte) []byte {
+	return tlv(0xa3, append(tlv(0x04, []byte(attr)), tlv(0x04, value)...))
+}
+
+func tlv(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+func berInt(v int) []byte {
+	out := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		out = append([]byte{byte(v)}, out...)
+	}
+	if out[0]&0x80 != 0 {
+		out = append([]byte{0}, out...)
+	}
+	return out
+}
+
+// readTLV splits the first BER element off data.
+func readTLV(data []byte) (tag byte, content, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("CLDAP Parse Error: short element")
+	}
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 4 || len(data) < 2+size {
+			return 0, nil, nil, fmt.Errorf("CLDAP Parse Error: bad length")
+		}
+		length = 0
+		for _, b := range data[2 : 2+size] {
+			length = length<<8 | int(b)
+		}
+		offset += size
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, fmt.Errorf("CLDAP Parse Error: truncated element")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// netlogonValue digs the Netlogon attribute value out of the SearchResultEntry.
+func netlogonValue(packet []byte) ([]byte, error) {
+	for len(packet) > 0 {
+		_, message, rest, err := readTLV(packet)
+		if err != nil {
+			return nil, err
+		}
+		packet = rest
+		_, _, op, err := readTLV(message) // messageID
+		if err != nil {
+			return nil, err
+		}
+		tag, entry, _, err := readTLV(op)
+		if err != nil {
+			return nil, err
+		}
+		if tag != 0x64 { // SearchResultEntry
+			continue
+		}
+		_, _, attrs, err := readTLV(entry) // objectName
+		if err != nil {
+			return nil, err
+		}
+		_, list, _, err := readTLV(attrs)
+		if err != nil {
+			return nil, err
+		}
+		for len(list) > 0 {
+			_, attr, next, err := readTLV(list)
+			if err != nil {
+				return nil, err
+			}
+			list = next
+			_, name, vals, err := readTLV(attr)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(string(name), "netlogon") {
+				continue
+			}
+			_, set, _, err := readTLV(vals)
+			if err != nil {
+				return nil, err
+			}
+			_, value, _, err := readTLV(set)
+			return value, err
+		}
+	}
+	return nil, fmt.Errorf("CLDAP Parse Error: no Netlogon attribute in response")
+}
+
+func parseNetlogon(data []byte) (*Netlogon, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("Netlogon Parse Error: short response")
+	}
+	n := &Netlogon{Opcode: binary.LittleEndian.Uint16(data[0:2])}
+	if n.Opcode != LOGON_SAM_LOGON_RESPONSE_EX && n.Opcode != LOGON_SAM_PAUSE_RESPONSE_EX && n.Opcode != LOGON_SAM_USER_UNKNOWN_EX {
+		return n, nil
+	}
+	if len(data) < 24 {
+		return n, fmt.Errorf("Netlogon Parse Error: short response")
+	}
+	n.Flags = binary.LittleEndian.Uint32(data[4:8])
+	n.DomainGUID = formatGUID(data[8:24])
+	offset := 24
+	fields := []*string{&n.Forest, &n.Domain, &n.HostName, &n.NetbiosDomain, &n.NetbiosName, &n.UserName, &n.DCSite, &n.ClientSite}
+	for _, field := range fields {
+		value, next, err := readName(data, offset)
+		if err != nil {
+			return n, err
+		}
+		*field, offset = value, next
+	}
+	if len(data) >= offset+4 {
+		n.NtVersion = binary.LittleEndian.Uint32(data[offset : offset+4])
+	}
+	return n, nil
+}
+
+// readName decodes an RFC 1035 name, compression pointers are relative to the start of data.
+func readName(data []byte, offset int) (string, int, error) {
+	labels := make([]string, 0)
+	next := -1
+	for hops := 0; hops < 32; hops++ {
+		if offset >= len(data) {
+			return "", 0, fmt.Errorf("Netlogon Parse Error: name out of range")
+		}
+		length := int(data[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(data) {
+				return "", 0, fmt.Errorf("Netlogon Parse Error: bad pointer")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(data[offset:offset+2]) & 0x3fff)
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, fmt.Errorf("Netlogon Parse Error: label out of range")
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, fmt.Errorf("Netlogon Parse Error: compression loop")
+}
+
+// formatGUID renders a little endian GUID in its usual text form.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]), binary.LittleEndian.Uint16(b[4:6]), binary.LittleEndian.Uint16(b[6:8]), b[8:10], b[10:16])
+}
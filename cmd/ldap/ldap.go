@@ -0,0 +1,36 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package ldap
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Defaults
+	THREAD_COUNT = 10
+	TIME_FORMAT  = "Mon, 2006-01-02 15:04:05"
+)
+
+const END_STRING = `
+[---FINISHED---]
+ Time End: %s
+ Took: %s
+`
+
+// LdapCmd groups the LDAP and CLDAP enumeration modes
+var LdapCmd = &cobra.Command{
+	Use:   "ldap",
+	Short: "Enumerates Active Directory domain controllers over LDAP and CLDAP",
+	Long: `
+[LDAP ENUMERATION]
+	[-- MODES --]
+	netlogon-users <Find valid accounts with CLDAP NetLogon pings>
+
+	[-- EXAMPLES --]
+	goEnum ldap netlogon-users -U users.txt -H 10.0.0.10 -D corp.local
+`,
+}
+
+type Key struct{}
@@ -0,0 +1,243 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/phaze228/genum/utils"
+	"github.com/spf13/cobra"
+)
+
+// Account name no directory should hold, used to spot DCs that answer known for everyone
+const CANARY_USER = "genum-canary-7f3a9c"
+
+const NETLOGON_START_STRING = `
+[CLDAP NETLOGON USER ENUMERATION]
+ Domain: %s
+ Hosts: %s
+ Users: %s
+ Time Start: %s
+`
+
+// NetlogonUsersCmd represents the ldap netlogon-users command
+var NetlogonUsersCmd = &cobra.Command{
+	Use:   "netlogon-users",
+	Short: "Enumerates valid domain accounts with CLDAP NetLogon pings",
+	Long: `
+[CLDAP NETLOGON USER ENUMERATION]
+	A domain controller answers a NetLogon ping carrying a User= filter with
+	LOGON_SAM_LOGON_RESPONSE_EX when the account exists and
+	LOGON_SAM_USER_UNKNOWN_EX when it does not. No Kerberos traffic or failed
+	logons are generated.
+
+	[-- REQUIRED --]
+	-U <User or file of users>
+	-H <Domain controller or file of domain controllers>
+
+	[-- OPTIONAL --]
+	-D <AD DNS domain, learned from the first DC when empty>
+	-T <Amount of Threads to run>
+	-d <Duration of timeout>
+	-p <Port which CLDAP acts on>
+	-v <Print unknown users as well>
+
+	[-- EXAMPLES --]
+	goEnum ldap netlogon-users -U users.txt -H 10.0.0.10 -D corp.local
+	goEnum ldap netlogon-users -U jdoe -H dcs.txt -T 4 -d 2s
+`,
+	PreRunE: validateNetlogonUsers,
+	RunE:    executeNetlogonUsers,
+}
+
+type Netlogon_Options struct {
+	utils.Options
+	Users   string
+	Hosts   string
+	Domain  string
+	Port    int
+	Threads int
+	Time    utils.Duration
+	Verbose bool
+}
+
+type userQuery struct {
+	Host string
+	User string
+}
+
+// userResults collects the accounts each DC reported as existing.
+type userResults struct {
+	mu     sync.Mutex
+	Valid  map[string][]string
+	Errata []error
+}
+
+func init() {
+	var duration utils.Duration = utils.Duration(time.Duration(3) * time.Second)
+	NetlogonUsersCmd.Flags().StringP("users", "U", "", "username or file with list of usernames")
+	NetlogonUsersCmd.Flags().StringP("hosts", "H", "", "domain controller or file with list of domain controllers")
+	NetlogonUsersCmd.Flags().StringP("domain", "D", "", "AD DNS domain sent in the DnsDomain filter: corp.local")
+	NetlogonUsersCmd.Flags().IntP("port", "p", CLDAP_PORT, "Port the CLDAP Service runs on")
+	NetlogonUsersCmd.Flags().IntP("threads", "T", THREAD_COUNT, "Thread Count: Default: 10")
+	NetlogonUsersCmd.Flags().VarP(&duration, "duration", "d", "Timeout: 3s, 10s...etc")
+	NetlogonUsersCmd.Flags().BoolP("verbose", "v", false, "Verbose output, prints unknown users too")
+	LdapCmd.AddCommand(NetlogonUsersCmd)
+}
+
+func validateNetlogonUsers(cmd *cobra.Command, args []string) error {
+	var options = new(Netlogon_Options)
+	err := options.AddRequired(cmd,
+		"users", &options.Users,
+		"hosts", &options.Hosts,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = options.Add(cmd,
+		"domain", &options.Domain,
+		"port", &options.Port,
+		"threads", &options.Threads,
+		"duration", &options.Time,
+		"verbose", &options.Verbose,
+	)
+	if err != nil {
+		return err
+	}
+	if options.Threads <= 0 {
+		options.Threads = THREAD_COUNT
+	}
+
+	cmd.SetContext(context.WithValue(cmd.Context(), Key{}, options))
+	return nil
+}
+
+func executeNetlogonUsers(cmd *cobra.Command, args []string) error {
+	validatedArgs := cmd.Context().Value(Key{})
+	if validatedArgs == nil {
+		return fmt.Errorf("[Command Line Options Error]")
+	}
+	opts, ok := validatedArgs.(*Netlogon_Options)
+	if !ok {
+		return fmt.Errorf("Invalid Type: %T", validatedArgs)
+	}
+	usernames := make([]string, 0)
+	hostnames := make([]string, 0)
+	utils.AppendFileContentsOrString(opts.Users, &usernames)
+	utils.AppendFileContentsOrString(opts.Hosts, &hostnames)
+
+	start_time := time.Now()
+	fmt.Printf(NETLOGON_START_STRING, orUnknown(opts.Domain), opts.Hosts, opts.Users, start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[DOMAIN CONTROLLERS]-----------")
+	hostnames = checkControllers(opts, hostnames)
+	if len(hostnames) == 0 {
+		return fmt.Errorf("No Domain Controller answered a NetLogon ping")
+	}
+
+	fmt.Println("\n------------[PROGRESS]---------------------")
+	results := &userResults{Valid: make(map[string][]string)}
+	queryChan := make(chan userQuery)
+	var wg sync.WaitGroup
+	wg.Add(opts.Threads)
+
+	go generateUserQuery(queryChan, usernames, hostnames)
+	for i := 0; i < opts.Threads; i++ {
+		go userWorker(queryChan, opts, &wg, results)
+	}
+	wg.Wait()
+
+	fmt.Println("\n---- [Results] ----")
+	users := make([]string, 0, len(results.Valid))
+	for user := range results.Valid {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		hosts := results.Valid[user]
+		sort.Strings(hosts)
+		fmt.Printf("[%s] %s\n", strings.Join(hosts, ", "), user)
+	}
+	fmt.Printf("%d of %d users valid, %d errors\n", len(users), len(usernames), len(results.Errata))
+	end_time := time.Now()
+	fmt.Printf(END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	return nil
+}
+
+// checkControllers pings every host without a user, learning the domain when
+// it was not given, and drops hosts that are not DCs or that claim every
+// account exists.
+func checkControllers(opts *Netlogon_Options, hosts []string) []string {
+	usable := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		info, err := NetlogonPing(host, opts.Port, opts.Domain, "", opts.Time.ToTime())
+		if err != nil {
+			color.Red("[%s] %v", host, err)
+			continue
+		}
+		if opts.Domain == "" && info.Domain != "" {
+			opts.Domain = info.Domain
+			fmt.Printf(" Domain: %s (learned from %s)\n", opts.Domain, host)
+		}
+		fmt.Printf("[%s] %s (%s\\%s) Site: %s\n", host, orUnknown(info.HostName), orUnknown(info.NetbiosDomain), orUnknown(info.NetbiosName), orUnknown(info.DCSite))
+
+		canary, err := NetlogonPing(host, opts.Port, opts.Domain, CANARY_USER, opts.Time.ToTime())
+		if err != nil {
+			color.Red("[%s] %v", host, err)
+			continue
+		}
+		if canary.UserKnown() {
+			color.Yellow("[%s] Answers known for a non existent account, skipping", host)
+			continue
+		}
+		usable = append(usable, host)
+	}
+	return usable
+}
+
+func generateUserQuery(qChan chan userQuery, users, hosts []string) {
+	defer close(qChan)
+	for _, u := range users {
+		if u == "" {
+			continue
+		}
+		for _, h := range hosts {
+			qChan <- userQuery{h, u}
+		}
+	}
+}
+
+func userWorker(qChan chan userQuery, opts *Netlogon_Options, wg *sync.WaitGroup, results *userResults) {
+	defer wg.Done()
+	for q := range qChan {
+		response, err := NetlogonPing(q.Host, opts.Port, opts.Domain, q.User, opts.Time.ToTime())
+		if err != nil {
+			fmt.Printf("[%s] %s: %v\n", q.Host, q.User, err)
+			results.mu.Lock()
+			results.Errata = append(results.Errata, err)
+			results.mu.Unlock()
+			continue
+		}
+		if !response.UserKnown() {
+			if opts.Verbose {
+				fmt.Printf("[%s] %s: unknown\n", q.Host, q.User)
+			}
+			continue
+		}
+		color.Green("[%s] %s: valid", q.Host, q.User)
+		results.mu.Lock()
+		results.Valid[q.User] = append(results.Valid[q.User], q.Host)
+		results.mu.Unlock()
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "?"
+	}
+	return s
+}
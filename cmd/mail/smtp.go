@@ -11,6 +11,7 @@ import (
 	"net"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +32,7 @@ const (
 	RCPT      = "RCPT"
 	RN        = "\r\n"
 	HELO      = "HELO x" + RN
+	EHLO      = "EHLO x" + RN
 	FROM_MAIL = "MAIL FROM:"
 	RCPT_TO   = "RCPT TO:"
 )
@@ -69,7 +71,7 @@ var SmtpCmd = &cobra.Command{
 	-F <From Address (for RCPT)>
 	-D <Domain to append to usernames>
 	-T <Amount of Threads to run>
-	-S <Toggle SSL>
+	-S <Implicit TLS (SMTPS), e.g. port 465>
 	-d <Duration of timeout>
 	-p <Port which Service acts on>
 
@@ -106,7 +108,7 @@ func init() {
 	SmtpCmd.Flags().IntP("threads", "T", THREAD_COUNT, "Thread Count: Default: 10")
 	SmtpCmd.Flags().VarP(&duration, "duration", "d", "Timeout: 3s, 10s...etc")
 	SmtpCmd.Flags().BoolP("verbose", "v", false, "Verbose output, prints nearly everything")
-	SmtpCmd.Flags().BoolP("ssl", "S", false, "Enable SSL")
+	SmtpCmd.Flags().BoolP("ssl", "S", false, "Implicit TLS (SMTPS): the TLS handshake comes before the SMTP banner, e.g. port 465")
 
 	// Here you will define your flags and configuration settings.
 
@@ -236,27 +238,39 @@ func qWorker(qChan chan string, args *SMTP_Options, wg *sync.WaitGroup, qFunc ut
 	}
 }
 
+// createSMTPConnection dials the server, with ssl the connection is implicit
+// TLS (SMTPS, port 465) and the banner arrives after the handshake.
 func createSMTPConnection(ssl bool, host string, port int, timeout time.Duration) (net.Conn, error) {
-	hostPort := fmt.Sprintf("%s:%d", host, port)
+	hostPort := net.JoinHostPort(host, strconv.Itoa(port))
 	if !ssl {
 		return net.DialTimeout("tcp", hostPort, timeout)
 	}
-	tlsConfig := &tls.Config{ServerName: host}
-	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", hostPort, tlsConfig)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", hostPort, &tls.Config{ServerName: host})
 	if err != nil {
 		return nil, fmt.Errorf("TLS Connection Error: %v", err)
 	}
+	return conn, nil
+}
+
+// StartTLS upgrades a freshly dialed SMTP connection: it reads the banner,
+// greets the server, issues STARTTLS and completes the TLS handshake.
+func StartTLS(conn net.Conn, tlsConfig *tls.Config) (*tls.Conn, error) {
 	writer := bufio.NewWriter(conn)
 	reader := bufio.NewReader(conn)
 
-	_, err = reader.ReadString('\n')
+	_, err := readReply(reader)
 	if err != nil {
 		return nil, fmt.Errorf("Banner Read Failure: %v", err)
 	}
+	fmt.Fprintf(writer, EHLO)
+	writer.Flush()
+	if _, err := readReply(reader); err != nil {
+		return nil, fmt.Errorf("EHLO Response Error: %v", err)
+	}
 	fmt.Fprintf(writer, "STARTTLS"+RN)
 	writer.Flush()
 
-	resp, err := reader.ReadString('\n')
+	resp, err := readReply(reader)
 	if err != nil {
 		return nil, fmt.Errorf("Error with TLS: %v", err)
 	}
@@ -271,6 +285,19 @@ func createSMTPConnection(ssl bool, host string, port int, timeout time.Duration
 	return tlsCon, nil
 }
 
+// readReply reads a possibly multiline SMTP reply and returns its last line.
+func readReply(reader *bufio.Reader) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if len(line) < 4 || line[3] != '-' {
+			return line, nil
+		}
+	}
+}
+
 func probeSMPT(host, user, mode, from string, port int, timeout time.Duration, ssl bool) (string, error) {
 	conn, err := createSMTPConnection(ssl, host, port, timeout)
 	if err != nil {
@@ -0,0 +1,352 @@
+package mdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/miekg/dns"
+	"github.com/phaze228/genum/utils"
+	"golang.org/x/net/ipv4"
+)
+
+// Top bit of the class field: cache flush in answers, unicast response in questions
+const CLASS_FLUSH = 1 << 15
+
+type Service struct {
+	Instance string
+	Type     string
+	Host     string
+	Port     uint16
+	TXT      []string
+}
+
+type Host struct {
+	Name     string
+	Addrs    []string
+	Services []*Service
+}
+
+// Browser sends one-shot mDNS queries and caches every record that comes
+// back, so later rounds only ask for what the responders left out.
+type Browser struct {
+	Verbose    bool
+	Types      []string
+	conn       *net.UDPConn
+	target     *net.UDPAddr
+	window     time.Duration
+	cache      map[string][]dns.RR
+	responders map[string][]string
+}
+
+func NewBrowser(target string, port int, iface string, window time.Duration) (*Browser, error) {
+	addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(target, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("Target Error: %v", err)
+	}
+	// Queries from a port other than 5353 are answered by unicast to that port
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, fmt.Errorf("Socket Error: %v", err)
+	}
+	if addr.IP.IsMulticast() {
+		p := ipv4.NewPacketConn(conn)
+		p.SetMulticastTTL(255)
+		if iface != "" {
+			ifi, err := net.InterfaceByName(iface)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("Interface Error: %v", err)
+			}
+			if err := p.SetMulticastInterface(ifi); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("Interface Error: %v", err)
+			}
+		}
+	}
+	return &Browser{
+		Types:      make([]string, 0),
+		conn:       conn,
+		target:     addr,
+		window:     window,
+		cache:      make(map[string][]dns.RR),
+		responders: make(map[string][]string),
+	}, nil
+}
+
+func (b *Browser) Close() {
+	b.conn.Close()
+}
+
+// Browse walks service types, instances and hosts. Without explicit service
+// types the DNS-SD meta query supplies them.
+func (b *Browser) Browse(services []string) error {
+	if len(services) == 0 {
+		if err := b.query(questions([]string{MDNS_META}, dns.TypePTR)); err != nil {
+			return err
+		}
+		services = b.ptrTargets(MDNS_META)
+	}
+	b.Types = services
+
+	if err := b.query(b.missing(services, dns.TypePTR)); err != nil {
+		return err
+	}
+	instances := make([]string, 0)
+	for _, service := range services {
+		instances = append(instances, b.ptrTargets(service)...)
+	}
+	if err := b.query(b.missing(instances, dns.TypeSRV, dns.TypeTXT)); err != nil {
+		return err
+	}
+	hosts := make([]string, 0)
+	for _, instance := range instances {
+		for _, rr := range b.cache[strings.ToLower(instance)] {
+			if srv, ok := rr.(*dns.SRV); ok {
+				hosts = append(hosts, srv.Target)
+			}
+		}
+	}
+	return b.query(b.missing(hosts, dns.TypeA, dns.TypeAAAA))
+}
+
+func questions(names []string, qtypes ...uint16) []dns.Question {
+	qs := make([]dns.Question, 0, len(names)*len(qtypes))
+	for _, name := range names {
+		for _, qtype := range qtypes {
+			qs = append(qs, dns.Question{Name: dns.Fqdn(name), Qtype: qtype, Qclass: dns.ClassINET})
+		}
+	}
+	return qs
+}
+
+// missing returns questions for the names and types the cache has nothing for.
+func (b *Browser) missing(names []string, qtypes ...uint16) []dns.Question {
+	qs := make([]dns.Question, 0)
+	for _, q := range questions(names, qtypes...) {
+		if len(b.lookup(q.Name, q.Qtype)) == 0 && !slices.Contains(qs, q) {
+			qs = append(qs, q)
+		}
+	}
+	return qs
+}
+
+// query sends every question in its own message, then collects answers for one window.
+func (b *Browser) query(qs []dns.Question) error {
+	if len(qs) == 0 {
+		return nil
+	}
+	for _, q := range qs {
+		msg := new(dns.Msg)
+		msg.Question = []dns.Question{q}
+		out, err := msg.Pack()
+		if err != nil {
+			return fmt.Errorf("Pack Error: %v", err)
+		}
+		if _, err := b.conn.WriteToUDP(out, b.target); err != nil {
+			return fmt.Errorf("Write Error: %v", err)
+		}
+	}
+	b.collect()
+	return nil
+}
+
+func (b *Browser) collect() {
+	buf := make([]byte, MDNS_BUF_SIZE)
+	b.conn.SetReadDeadline(time.Now().Add(b.window))
+	for {
+		n, src, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				fmt.Printf("[WARNING] Read Error: %v\n", err)
+			}
+			return
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(buf[:n]); err != nil || !msg.Response {
+			continue
+		}
+		for _, rr := range append(append(msg.Answer, msg.Ns...), msg.Extra...) {
+			b.add(rr, src.IP.String())
+		}
+	}
+}
+
+func (b *Browser) add(rr dns.RR, responder string) {
+	if rr.Header().Rrtype == dns.TypeOPT || rr.Header().Rrtype == dns.TypeNSEC {
+		return
+	}
+	rr.Header().Class &^= CLASS_FLUSH
+	name := strings.ToLower(rr.Header().Name)
+	for _, cached := range b.cache[name] {
+		if dns.IsDuplicate(cached, rr) {
+			return
+		}
+	}
+	if b.Verbose {
+		fmt.Printf("[%s] %s\n", responder, rr.String())
+	}
+	b.cache[name] = append(b.cache[name], rr)
+	if !slices.Contains(b.responders[name], responder) {
+		b.responders[name] = append(b.responders[name], responder)
+	}
+}
+
+func (b *Browser) lookup(name string, qtype uint16) []dns.RR {
+	rrs := make([]dns.RR, 0)
+	for _, rr := range b.cache[strings.ToLower(dns.Fqdn(name))] {
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+func (b *Browser) ptrTargets(name string) []string {
+	targets := make([]string, 0)
+	for _, rr := range b.lookup(name, dns.TypePTR) {
+		targets = append(targets, rr.(*dns.PTR).Ptr)
+	}
+	sort.Strings(targets)
+	return slices.Compact(targets)
+}
+
+// Hosts groups the resolved service instances by the host they point at.
+// Instances without an SRV record are returned under an empty host name.
+func (b *Browser) Hosts() []*Host {
+	hosts := make(map[string]*Host)
+	get := func(name string) *Host {
+		key := strings.ToLower(name)
+		if host, ok := hosts[key]; ok {
+			return host
+		}
+		host := &Host{Name: name, Addrs: make([]string, 0), Services: make([]*Service, 0)}
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			for _, rr := range b.lookup(name, qtype) {
+				switch v := rr.(type) {
+				case *dns.A:
+					host.Addrs = append(host.Addrs, v.A.String())
+				case *dns.AAAA:
+					host.Addrs = append(host.Addrs, v.AAAA.String())
+				}
+			}
+		}
+		hosts[key] = host
+		return host
+	}
+	for _, serviceType := range b.Types {
+		for _, instance := range b.ptrTargets(serviceType) {
+			service := &Service{Instance: instance, Type: serviceType, TXT: make([]string, 0)}
+			for _, rr := range b.lookup(instance, dns.TypeTXT) {
+				for _, txt := range rr.(*dns.TXT).Txt {
+					if txt != "" {
+						service.TXT = append(service.TXT, txt)
+					}
+				}
+			}
+			srvs := b.lookup(instance, dns.TypeSRV)
+			if len(srvs) == 0 {
+				host := get("")
+				host.Services = append(host.Services, service)
+				continue
+			}
+			srv := srvs[0].(*dns.SRV)
+			service.Host, service.Port = srv.Target, srv.Port
+			host := get(srv.Target)
+			host.Services = append(host.Services, service)
+		}
+	}
+	list := make([]*Host, 0, len(hosts))
+	for _, host := range hosts {
+		list = append(list, host)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Responders returns every address that sent at least one record.
+func (b *Browser) Responders() []string {
+	responders := make([]string, 0)
+	for _, list := range b.responders {
+		responders = append(responders, list...)
+	}
+	sort.Strings(responders)
+	return slices.Compact(responders)
+}
+
+func (b *Browser) Print() {
+	color.Blue("[ Service Types ]")
+	printTree(b.Types, "No service types")
+
+	hosts := b.Hosts()
+	instances := 0
+	color.Blue("[ Hosts ]")
+	for _, host := range hosts {
+		name := host.Name
+		if name == "" {
+			name = "unresolved instances"
+		}
+		color.Yellow("  [ %s ] %s", name, strings.Join(host.Addrs, ", "))
+		lines := make([]string, 0, len(host.Services))
+		for _, service := range host.Services {
+			line := service.Instance
+			if service.Port != 0 {
+				line = fmt.Sprintf("%s\t:%d", line, service.Port)
+			}
+			if len(service.TXT) > 0 {
+				line = fmt.Sprintf("%s\t%s", line, strings.Join(service.TXT, " | "))
+			}
+			lines = append(lines, line)
+		}
+		instances += len(host.Services)
+		printTree(lines, "-")
+	}
+	if len(hosts) == 0 {
+		fmt.Printf("  |_____No hosts\n\n")
+	}
+
+	color.Blue("[ Summary ]")
+	fmt.Printf("  | \tResponders: %s\n", orDefault(strings.Join(b.Responders(), ", "), "-"))
+	fmt.Printf("  |_____Service Types: %d | Instances: %d | Hosts: %d\n", len(b.Types), instances, len(hosts))
+}
+
+func printTree(lines []string, empty string) {
+	if len(lines) == 0 {
+		fmt.Printf("  |_____%s\n\n", empty)
+		return
+	}
+	for i, line := range lines {
+		if i == len(lines)-1 {
+			fmt.Printf("  |_____%s\n\n", line)
+			break
+		}
+		fmt.Printf("  | \t%s\n", line)
+	}
+}
+
+// serviceTypes reads service types and completes them to <type>.local.
+func serviceTypes(value string) []string {
+	raw := make([]string, 0)
+	utils.AppendFileContentsOrString(value, &raw)
+	services := make([]string, 0)
+	for _, entry := range raw {
+		for _, service := range strings.Split(entry, ",") {
+			service = strings.ToLower(strings.TrimSuffix(service, "."))
+			if service == "" {
+				continue
+			}
+			if !strings.HasSuffix(service, ".local") {
+				service += ".local"
+			}
+			services = append(services, dns.Fqdn(service))
+		}
+	}
+	return services
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/phaze228/genum/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Defaults
+	MDNS_PORT      = 5353
+	MDNS_GROUP     = "224.0.0.251"
+	MDNS_META      = "_services._dns-sd._udp.local."
+	MDNS_BUF_SIZE  = 9000
+	DEFAULT_WINDOW = 2 * time.Second
+	TIME_FORMAT    = "Mon, 2006-01-02 15:04:05"
+)
+
+const START_STRING = `
+[MDNS SERVICE DISCOVERY]
+ Target: %s
+ Services: %s
+ Time Start: %s
+`
+const END_STRING = `
+[---FINISHED---]
+ Time End: %s
+ Took: %s
+`
+
+// MdnsCmd represents the mdns command
+var MdnsCmd = &cobra.Command{
+	Use:   "mdns",
+	Short: "Discovers hosts and services on the local segment with mDNS and DNS-SD",
+	Long: `
+[MDNS SERVICE DISCOVERY]
+	Browses DNS-SD service types, resolves every instance's SRV, TXT and
+	address records and groups what answered by host. Queries leave from an
+	ephemeral port so responders answer by unicast.
+
+	[-- OPTIONAL --]
+	-s <Service type or file of service types, skips browsing _services._dns-sd._udp>
+	-H <Ask a single host on its mDNS port instead of the multicast group>
+	-i <Interface to send multicast queries on>
+	-p <Port which mDNS acts on>
+	-d <Time to collect answers after every query round>
+	-v <Print every record received>
+
+	[-- EXAMPLES --]
+	goEnum mdns
+	goEnum mdns -i eth0 -d 5s
+	goEnum mdns -s _ipp._tcp,_smb._tcp
+	goEnum mdns -H 192.168.1.20
+`,
+	PreRunE: validateMDNS,
+	RunE:    executeMDNS,
+}
+
+type MDNS_Options struct {
+	utils.Options
+	Services  string
+	Host      string
+	Interface string
+	Port      int
+	Time      utils.Duration
+	Verbose   bool
+}
+
+type Key struct{}
+
+func init() {
+	var duration utils.Duration = utils.Duration(DEFAULT_WINDOW)
+	MdnsCmd.Flags().StringP("services", "s", "", "service type, comma separated service types or file of service types: _ipp._tcp")
+	MdnsCmd.Flags().StringP("host", "H", "", "host to query directly instead of the multicast group")
+	MdnsCmd.Flags().StringP("interface", "i", "", "interface to send multicast queries on: eth0")
+	MdnsCmd.Flags().IntP("port", "p", MDNS_PORT, "Port the mDNS Service runs on")
+	MdnsCmd.Flags().VarP(&duration, "duration", "d", "Answer collection window per round: 2s, 5s...etc")
+	MdnsCmd.Flags().BoolP("verbose", "v", false, "Verbose output, prints every record received")
+}
+
+func validateMDNS(cmd *cobra.Command, args []string) error {
+	var options = new(MDNS_Options)
+	err := options.Add(cmd,
+		"services", &options.Services,
+		"host", &options.Host,
+		"interface", &options.Interface,
+		"port", &options.Port,
+		"duration", &options.Time,
+		"verbose", &options.Verbose,
+	)
+	if err != nil {
+		return err
+	}
+	if options.Time.ToTime() <= 0 {
+		options.Time = utils.Duration(DEFAULT_WINDOW)
+	}
+
+	cmd.SetContext(context.WithValue(cmd.Context(), Key{}, options))
+	return nil
+}
+
+func executeMDNS(cmd *cobra.Command, args []string) error {
+	validatedArgs := cmd.Context().Value(Key{})
+	if validatedArgs == nil {
+		return fmt.Errorf("[Command Line Options Error]")
+	}
+	opts, ok := validatedArgs.(*MDNS_Options)
+	if !ok {
+		return fmt.Errorf("Invalid Type: %T", validatedArgs)
+	}
+
+	target := MDNS_GROUP
+	if opts.Host != "" {
+		target = opts.Host
+	}
+	services := make([]string, 0)
+	if opts.Services != "" {
+		services = serviceTypes(opts.Services)
+	}
+
+	start_time := time.Now()
+	fmt.Printf(START_STRING, target, orDefault(opts.Services, MDNS_META), start_time.Format(TIME_FORMAT))
+	fmt.Println("\n------------[PROGRESS]---------------------")
+
+	browser, err := NewBrowser(target, opts.Port, opts.Interface, opts.Time.ToTime())
+	if err != nil {
+		return err
+	}
+	defer browser.Close()
+	browser.Verbose = opts.Verbose
+	if err := browser.Browse(services); err != nil {
+		return err
+	}
+	browser.Print()
+
+	end_time := time.Now()
+	fmt.Printf(END_STRING, end_time.Format(TIME_FORMAT), end_time.Sub(start_time).String())
+	return nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 
 	"github.com/phaze228/genum/cmd/dns"
+	"github.com/phaze228/genum/cmd/ldap"
 	"github.com/phaze228/genum/cmd/mail"
+	"github.com/phaze228/genum/cmd/mdns"
 	"github.com/spf13/cobra"
 )
 
@@ -47,5 +49,7 @@ func init() {
 	rootCmd.AddCommand(mail.SmtpCmd)
 	rootCmd.AddCommand(mail.Pop3Cmd)
 	rootCmd.AddCommand(dns.DNSCmd)
+	rootCmd.AddCommand(ldap.LdapCmd)
+	rootCmd.AddCommand(mdns.MdnsCmd)
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
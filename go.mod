@@ -2,18 +2,22 @@ module github.com/phaze228/genum
 
 go 1.23.2
 
-require github.com/spf13/cobra v1.8.1
+require (
+	github.com/fatih/color v1.18.0
+	github.com/miekg/dns v1.1.62
+	github.com/oschwald/maxminddb-golang v1.13.1
+	github.com/spf13/cobra v1.8.1
+	golang.org/x/net v0.27.0
+)
 
 require (
-	github.com/fatih/color v1.18.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/miekg/dns v1.1.62 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/mod v0.18.0 // indirect
-	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
 )
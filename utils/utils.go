@@ -56,7 +56,7 @@ func AppendFileContentsOrString(name string, arr *[]string) {
 		*arr = append(*arr, text)
 	}
 	if err := scanner.Err(); err != nil {
-		fmt.Errorf("%v\n", err)
+		fmt.Printf("[ERROR] %v\n", err)
 	}
 
 }
@@ -143,6 +143,16 @@ func (opt *Options) Add(cmd *cobra.Command, flags ...interface{}) error {
 				return fmt.Errorf(parseError, name, err)
 			}
 			*v = field
+		case *Duration:
+			flag := cmd.Flags().Lookup(name)
+			if flag == nil {
+				return fmt.Errorf(parseError, name, "flag not defined")
+			}
+			field, ok := flag.Value.(*Duration)
+			if !ok {
+				return fmt.Errorf(parseError, name, flag.Value.Type())
+			}
+			*v = *field
 		default:
 			fmt.Println(v)
 			return fmt.Errorf("Unsupported flag type: %T: %T", value, v)